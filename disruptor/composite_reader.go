@@ -23,4 +23,14 @@ func (this compositeReader) Close() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (this compositeReader) halt() {
+	for _, item := range this {
+		if reader, ok := item.(halter); ok {
+			reader.halt()
+		} else {
+			_ = item.Close()
+		}
+	}
+}
@@ -19,4 +19,8 @@ func (this *Cursor) Store(value int64) {
 
 func (this *Cursor) Load() int64 {
 	return atomic.LoadInt64(&this[0])
-}
\ No newline at end of file
+}
+
+func (this *Cursor) CompareAndSwap(old, new int64) bool {
+	return atomic.CompareAndSwapInt64(&this[0], old, new)
+}
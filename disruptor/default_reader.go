@@ -9,6 +9,7 @@ import (
 const (
 	stateRunning = iota
 	stateClosed
+	stateHalted
 )
 
 type DefaultReader struct {
@@ -18,6 +19,8 @@ type DefaultReader struct {
 	upstream	Barrier // all of the readers have advanced up to this sequence
 	waiter		WaitStrategy 
 	consumer	Consumer
+	metrics		*readerMetrics
+	maxBatch	int64 // zero hands every available sequence to the consumer at once
 }
 
 func NewReader(current, written *Cursor, upstream Barrier, waiter WaitStrategy, consumer Consumer) *DefaultReader {
@@ -28,6 +31,7 @@ func NewReader(current, written *Cursor, upstream Barrier, waiter WaitStrategy,
 		upstream: upstream,
 		waiter:   waiter,
 		consumer: consumer,
+		metrics:  &readerMetrics{},
 	}
 }
 
@@ -35,21 +39,25 @@ func (this *DefaultReader) Read() {
 	var gateCount, idleCount, lower, upper int64
 	var current = this.current.Load()
 
-	for {
+	for atomic.LoadInt64(&this.state) != stateHalted {
 		lower = current + 1
 		upper = this.upstream.Load()
 
 		if lower <= upper {
+			upper = limitBatch(lower, upper, this.maxBatch)
 			this.consumer.Consume(lower, upper)
 			this.current.Store(upper)
+			this.metrics.batch(lower, upper)
 			current = upper
 		} else if upper = this.written.Load(); lower <= upper {
 			gateCount++
 			idleCount = 0
+			this.metrics.gate()
 			this.waiter.Gate(gateCount)
 		} else if atomic.LoadInt64(&this.state) == stateRunning {
 			idleCount++
 			gateCount = 0
+			this.metrics.idle()
 			this.waiter.Idle(idleCount)
 		} else {
 			break
@@ -61,7 +69,13 @@ func (this *DefaultReader) Read() {
 	}
 }
 
+// Close lets the reader exit once it has caught up with the writer
 func (this *DefaultReader) Close() error {
-	atomic.StoreInt64(&this.state, stateClosed)
+	atomic.CompareAndSwapInt64(&this.state, stateRunning, stateClosed)
 	return nil
+}
+
+// halt makes the reader exit after the current batch, even if it has not caught up
+func (this *DefaultReader) halt() {
+	atomic.StoreInt64(&this.state, stateHalted)
 }
\ No newline at end of file
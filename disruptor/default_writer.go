@@ -1,11 +1,15 @@
 package disruptor
 
-import "runtime"
+import (
+	"runtime"
+	"sync/atomic"
+)
 
 
 const SpinMask = 1024*16 - 1
 
 type DefaultWriter struct {
+	state		int64
 	written		*Cursor  // the ring buffer has been written up to this sequence
 	upstream	Barrier	 // all of the readers have advanced up to this sequence
 	capacity	int64
@@ -15,6 +19,7 @@ type DefaultWriter struct {
 
 func NewWriter(written *Cursor, upstream Barrier, capacity int64) *DefaultWriter {
 	return &DefaultWriter{
+		state: stateRunning,
 		upstream: upstream,
 		written: written,
 		capacity: capacity,
@@ -27,10 +32,19 @@ func (this *DefaultWriter) Reserve(count int64) int64 {
 	if count <= 0 {
 		panic(ErrMinimumReservationSize)
 	}
+	if atomic.LoadInt64(&this.state) != stateRunning {
+		panic(ErrClosed)
+	}
 	this.previous += count
 	for spin:=int64(0); this.previous- this.capacity > this.gate; spin++ {
 		if spin & SpinMask == 0 {
 			runtime.Gosched() //LockSupport.parkNanos(1L)
+
+			// halted readers never free the slots we are waiting for
+			if atomic.LoadInt64(&this.state) != stateRunning {
+				this.previous -= count
+				panic(ErrClosed)
+			}
 		}
 		this.gate = this.upstream.Load()
 	}
@@ -41,3 +55,22 @@ func (this *DefaultWriter) Commit(_, upper int64) {
 	this.written.Store(upper)
 }
 
+// close stops accepting reservations, a reservation which is already granted may still be committed
+func (this *DefaultWriter) close() {
+	atomic.StoreInt64(&this.state, stateClosed)
+}
+
+// remaining is the number of committed sequences which not every reader has processed yet
+func (this *DefaultWriter) remaining() int64 {
+	if remaining := this.written.Load() - this.upstream.Load(); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// rollback gives back the latest reservation before it has been committed
+func (this *DefaultWriter) rollback(lower, upper int64) {
+	if upper == this.previous {
+		this.previous = lower - 1
+	}
+}
@@ -1,13 +1,174 @@
 package disruptor
 
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrUnsupportedWriter = errors.New("the writer cannot be drained")
+
+// drainer is implemented by writers which know how far the readers trail behind them
+type drainer interface {
+	close()
+	remaining() int64
+}
+
+// halter is implemented by readers which can stop before catching up with the writer
+type halter interface {
+	halt()
+}
+
 type Disruptor struct {
 	Writer
 	Reader
+	lifecycle	*lifecycle
+	topology	*topology // nil unless the disruptor was created by New
+}
+
+// lifecycle tracks whether Read is running so that shutting down can wait for the readers to exit
+type lifecycle struct {
+	reading		int32
+	stopped		chan struct{}
+	once		sync.Once
 }
 
 func NewDisruptor(writer Writer, reader Reader) Disruptor {
 	return Disruptor{
 		Writer: writer,
 		Reader: reader,
+		lifecycle: &lifecycle{stopped: make(chan struct{})},
+	}
+}
+
+// Read blocks until all of the readers have exited
+func (this Disruptor) Read() {
+	atomic.StoreInt32(&this.lifecycle.reading, 1)
+	defer this.lifecycle.once.Do(func() { close(this.lifecycle.stopped) })
+
+	if this.topology != nil {
+		for _, exporter := range this.topology.exporters {
+			go exporter.run(this.topology.stats, this.lifecycle.stopped)
+		}
+	}
+
+	this.Reader.Read()
+}
+
+// Stats takes a snapshot of the written cursor and of every handler, a disruptor which was not
+// created by New has no named handlers
+func (this Disruptor) Stats() Stats {
+	if this.topology == nil {
+		if writer, ok := this.Writer.(*DefaultWriter); ok {
+			return Stats{Written: writer.written.Load()}
+		}
+		return Stats{}
+	}
+	return this.topology.stats()
+}
+
+// Close lets every reader, including the attached ones, exit once it has caught up with the writer
+func (this Disruptor) Close() error {
+	if this.topology != nil {
+		this.topology.closeAttached()
+	}
+	return this.Reader.Close()
+}
+
+// Attach starts the handlers declared by WithHandler, WithWorkerPool or WithEventPoller options
+// while the disruptor is running. WithConsumerGroup is rejected, the other options are ignored.
+// A new handler starts with the sequence after the last written one and may depend on any handler
+// which is already running.
+func (this Disruptor) Attach(options ...Option) error {
+	if this.topology == nil {
+		return ErrStaticTopology
+	}
+
+	var declared Wireup
+	for _, option := range options {
+		option(&declared)
+	}
+	if declared.emptyGroup || declared.groups > 0 {
+		return errConsumerGroupAttach
+	}
+
+	return this.topology.attach(declared.handlers)
+}
+
+// Detach stops the named handler after its current batch and lets the writer stop waiting on it,
+// it fails while other handlers depend on the handler or when it is the last handler
+func (this Disruptor) Detach(name string) error {
+	if this.topology == nil {
+		return ErrStaticTopology
+	}
+	return this.topology.detach(name)
+}
+
+// Shutdown stops accepting reservations and waits until every reader has processed all committed
+// sequences before closing the readers. When the context expires first the readers are halted.
+// It returns how many committed sequences were left unprocessed.
+func (this Disruptor) Shutdown(ctx context.Context) (int64, error) {
+	writer, ok := this.Writer.(drainer)
+	if !ok {
+		return 0, ErrUnsupportedWriter
+	}
+	writer.close()
+
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	for writer.remaining() > 0 {
+		select {
+		case <-ctx.Done():
+			return this.Halt(), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	_ = this.Close()
+
+	if atomic.LoadInt32(&this.lifecycle.reading) == 1 {
+		select {
+		case <-ctx.Done():
+			return this.Halt(), ctx.Err()
+		case <-this.lifecycle.stopped:
+		}
+	}
+	if this.topology != nil {
+		this.topology.waitAttached()
 	}
-}
\ No newline at end of file
+
+	return writer.remaining(), nil
+}
+
+// Halt stops accepting reservations and stops the readers after their current batch, whether
+// they have caught up or not. It returns how many committed sequences were left unprocessed.
+func (this Disruptor) Halt() int64 {
+	writer, ok := this.Writer.(drainer)
+	if ok {
+		writer.close()
+	}
+
+	if reader, ok := this.Reader.(halter); ok {
+		reader.halt()
+	} else {
+		_ = this.Reader.Close()
+	}
+	if this.topology != nil {
+		this.topology.haltAttached()
+	}
+
+	if atomic.LoadInt32(&this.lifecycle.reading) == 1 {
+		<-this.lifecycle.stopped
+	}
+	if this.topology != nil {
+		this.topology.waitAttached()
+	}
+
+	if !ok {
+		return 0
+	}
+	return writer.remaining()
+}
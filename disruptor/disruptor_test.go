@@ -0,0 +1,299 @@
+package disruptor
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowConsumer sleeps for every batch so that the writer gets far ahead of it
+type slowConsumer struct {
+	countingConsumer
+}
+
+func (this *slowConsumer) Consume(lower, upper int64) {
+	time.Sleep(time.Millisecond)
+	this.countingConsumer.Consume(lower, upper)
+}
+
+func publish(myDisruptor Disruptor, count int64) {
+	for i := int64(0); i < count; i++ {
+		sequence := myDisruptor.Reserve(1)
+		myDisruptor.Commit(sequence, sequence)
+	}
+}
+
+func TestShutdownDrainsCommittedSequences(t *testing.T) {
+	consumer := &slowConsumer{}
+	myDisruptor := New(WithCapacity(64), WithHandler("slow", consumer))
+	go myDisruptor.Read()
+
+	publish(myDisruptor, 64)
+
+	unprocessed, err := myDisruptor.Shutdown(context.Background())
+	if err != nil || unprocessed != 0 {
+		t.Fatalf("expected a clean drain, got %d unprocessed and %v", unprocessed, err)
+	}
+	if count := atomic.LoadInt64(&consumer.count); count != 64 {
+		t.Fatalf("expected 64 events, got %d", count)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != ErrClosed {
+			t.Fatalf("expected reserving after shutdown to panic with ErrClosed, got %v", recovered)
+		}
+	}()
+	myDisruptor.Reserve(1)
+}
+
+func TestHaltReportsUnprocessedSequences(t *testing.T) {
+	consumer := &slowConsumer{}
+	myDisruptor := New(WithCapacity(64), WithHandler("slow", consumer))
+
+	publish(myDisruptor, 64)
+	go myDisruptor.Read()
+	time.Sleep(time.Millisecond)
+
+	unprocessed := myDisruptor.Halt()
+	if processed := atomic.LoadInt64(&consumer.count); processed+unprocessed != 64 {
+		t.Fatalf("expected processed and unprocessed to add up to 64, got %d and %d", processed, unprocessed)
+	}
+}
+
+func TestEventPoller(t *testing.T) {
+	upstream := &slowConsumer{}
+	poller := NewEventPoller()
+	myDisruptor := New(
+		WithCapacity(64),
+		WithHandler("slow", upstream),
+		WithEventPoller("tick", poller, After("slow")),
+	)
+
+	consumer := &countingConsumer{}
+	if state := poller.Poll(consumer); state != PollIdle {
+		t.Fatalf("expected an idle poller, got %s", state)
+	}
+
+	publish(myDisruptor, 16)
+	if state := poller.Poll(consumer); state != PollGating {
+		t.Fatalf("expected a gated poller, got %s", state)
+	}
+
+	go myDisruptor.Read()
+	for consumer.count < 16 {
+		if state := poller.Poll(consumer); state == PollIdle {
+			t.Fatalf("expected the poller to process all 16 events, got %d", consumer.count)
+		}
+	}
+
+	if unprocessed, err := myDisruptor.Shutdown(context.Background()); err != nil || unprocessed != 0 {
+		t.Fatalf("expected a clean drain, got %d unprocessed and %v", unprocessed, err)
+	}
+}
+
+// rangeConsumer records the first and the last sequence and fails on gaps
+type rangeConsumer struct {
+	t           *testing.T
+	first, last int64
+	countingConsumer
+}
+
+func (this *rangeConsumer) Consume(lower, upper int64) {
+	if count := atomic.LoadInt64(&this.count); count == 0 {
+		this.first = lower
+	} else if lower != this.last+1 {
+		this.t.Errorf("expected sequence %d, got %d", this.last+1, lower)
+	}
+	this.last = upper
+	this.countingConsumer.Consume(lower, upper)
+}
+
+func TestAttachAndDetach(t *testing.T) {
+	journal := &rangeConsumer{t: t}
+	myDisruptor := New(WithCapacity(16), WithHandler("journal", journal))
+	go myDisruptor.Read()
+
+	publish(myDisruptor, 100)
+
+	tap := &rangeConsumer{t: t}
+	if err := myDisruptor.Attach(WithHandler("tap", tap, After("journal"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := myDisruptor.Attach(WithHandler("tap", tap)); err == nil {
+		t.Fatal("expected attaching a duplicate name to fail")
+	}
+	if err := myDisruptor.Detach("journal"); err == nil {
+		t.Fatal("expected detaching a handler with dependents to fail")
+	}
+
+	publish(myDisruptor, 100)
+	for atomic.LoadInt64(&tap.count) < 100 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := myDisruptor.Detach("tap"); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(myDisruptor, 100)
+	if unprocessed, err := myDisruptor.Shutdown(context.Background()); err != nil || unprocessed != 0 {
+		t.Fatalf("expected a clean drain, got %d unprocessed and %v", unprocessed, err)
+	}
+
+	if journal.count != 300 || journal.first != 0 || journal.last != 299 {
+		t.Fatalf("expected the journal to consume 0 to 299, got %d to %d", journal.first, journal.last)
+	}
+	if tap.count != 100 || tap.first != 100 || tap.last != 199 {
+		t.Fatalf("expected the tap to consume 100 to 199, got %d to %d", tap.first, tap.last)
+	}
+}
+
+func TestDetachLastHandler(t *testing.T) {
+	myDisruptor := New(WithCapacity(4), WithHandler("a", &countingConsumer{}))
+	go myDisruptor.Read()
+
+	if err := myDisruptor.Detach("a"); err == nil {
+		t.Fatal("expected detaching the last handler to fail")
+	}
+
+	// the writer has to keep waiting on the replacement, capacity 4 overruns it at once otherwise
+	if err := myDisruptor.Attach(WithHandler("b", &slowConsumer{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := myDisruptor.Detach("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		publish(myDisruptor, 1)
+		if lag := myDisruptor.Stats().Readers[0].Lag; lag > 4 {
+			t.Fatalf("expected the writer to wait for the replacement, it is %d sequences behind", lag)
+		}
+	}
+	if unprocessed, err := myDisruptor.Shutdown(context.Background()); err != nil || unprocessed != 0 {
+		t.Fatalf("expected a clean drain, got %d unprocessed and %v", unprocessed, err)
+	}
+}
+
+// slotChecker verifies that every slot still holds its own sequence when it is consumed
+type slotChecker struct {
+	ring        *RingBuffer[int64]
+	overwritten int64
+}
+
+func (this *slotChecker) Consume(lower, upper int64) {
+	for sequence := lower; sequence <= upper; sequence++ {
+		if atomic.LoadInt64(this.ring.Get(sequence)) != sequence {
+			atomic.AddInt64(&this.overwritten, 1)
+		}
+	}
+}
+
+func TestAttachWhileWriting(t *testing.T) {
+	// the writer has to run while Attach is gating the tap, even on a single processor
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	ring := NewRingBuffer[int64](4)
+	myDisruptor := New(WithCapacity(4), WithHandler("base", &countingConsumer{}))
+	go myDisruptor.Read()
+
+	var stop int32
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for atomic.LoadInt32(&stop) == 0 {
+			sequence := myDisruptor.Reserve(1)
+			atomic.StoreInt64(ring.Get(sequence), sequence)
+			myDisruptor.Commit(sequence, sequence)
+		}
+	}()
+
+	// the tap is attached while the writer is running at full speed
+	checker := &slotChecker{ring: ring}
+	for i := 0; i < 2000; i++ {
+		if err := myDisruptor.Attach(WithHandler("tap", checker)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Microsecond)
+		if err := myDisruptor.Detach("tap"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	atomic.StoreInt32(&stop, 1)
+	<-written
+
+	if overwritten := atomic.LoadInt64(&checker.overwritten); overwritten > 0 {
+		t.Fatalf("expected the tap to read only the slots of its sequences, %d were overwritten", overwritten)
+	}
+	_, _ = myDisruptor.Shutdown(context.Background())
+}
+
+func TestStats(t *testing.T) {
+	exported := make(chan Stats, 1)
+	poller := NewEventPoller()
+	myDisruptor := New(
+		WithCapacity(64),
+		WithHandler("journal", &countingConsumer{}),
+		WithWorkerPool("decode", 2, &countingConsumer{}, After("journal")),
+		WithEventPoller("tick", poller, After("decode")),
+		WithStatsExporter(time.Millisecond, func(stats Stats) {
+			select {
+			case exported <- stats:
+			default:
+			}
+		}),
+	)
+	go myDisruptor.Read()
+
+	publish(myDisruptor, 32)
+	for myDisruptor.Stats().Readers[2].Sequence < 31 {
+		poller.Poll(&countingConsumer{})
+	}
+
+	stats := myDisruptor.Stats()
+	if stats.Written != 31 || len(stats.Readers) != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	for _, reader := range stats.Readers {
+		if reader.Lag != 0 || reader.Sequence != 31 {
+			t.Fatalf("expected %s to have caught up, got %+v", reader.Name, reader)
+		}
+	}
+	if decode := stats.Readers[1]; decode.Name != "decode" || decode.Batches[0] != 32 {
+		t.Fatalf("expected the worker pool to consume 32 batches of one, got %+v", decode)
+	}
+	if exportedStats := <-exported; len(exportedStats.Readers) != 3 {
+		t.Fatalf("unexpected exported stats %+v", exportedStats)
+	}
+
+	_, _ = myDisruptor.Shutdown(context.Background())
+}
+
+// batchHandler records the size of every batch it was handed
+type batchHandler struct {
+	size    int64
+	batches []int64
+}
+
+func (this *batchHandler) OnEvent(sequence int64, endOfBatch bool) {
+	if this.size++; endOfBatch {
+		this.batches = append(this.batches, this.size)
+		this.size = 0
+	}
+}
+
+func TestEventHandlerMaxBatchSize(t *testing.T) {
+	handler := &batchHandler{}
+	myDisruptor := New(WithCapacity(64), WithEventHandler("flush", handler, MaxBatchSize(10)))
+
+	publish(myDisruptor, 25)
+	go myDisruptor.Read()
+	if _, err := myDisruptor.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(handler.batches) != 3 || handler.batches[0] != 10 || handler.batches[1] != 10 || handler.batches[2] != 5 {
+		t.Fatalf("expected batches of 10, 10 and 5, got %v", handler.batches)
+	}
+}
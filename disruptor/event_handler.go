@@ -0,0 +1,54 @@
+package disruptor
+
+import "io"
+
+// EventHandler receives the sequences of a batch one at a time, endOfBatch is set for the last
+// one so that the handler knows when to flush to disk or to the network
+type EventHandler interface {
+	OnEvent(sequence int64, endOfBatch bool)
+}
+
+// HandleEvents adapts the event handler to a consumer, the consumer closes the handler when
+// it implements io.Closer
+func HandleEvents(handler EventHandler) Consumer {
+	return eventConsumer{handler: handler}
+}
+
+type eventConsumer struct {
+	handler EventHandler
+}
+
+func (this eventConsumer) Consume(lower, upper int64) {
+	for sequence := lower; sequence <= upper; sequence++ {
+		this.handler.OnEvent(sequence, sequence == upper)
+	}
+}
+
+func (this eventConsumer) Close() error {
+	if closer, ok := this.handler.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// WithEventHandler appends a named event handler, see WithHandler
+func WithEventHandler(name string, handler EventHandler, options ...HandlerOption) Option {
+	return WithHandler(name, HandleEvents(handler), options...)
+}
+
+// MaxBatchSize splits the sequences which are available to the handler into batches of at most
+// the size, so that a large backlog does not keep the handler from flushing. It does not apply
+// to worker pools, their workers always consume one sequence at a time.
+func MaxBatchSize(value int64) HandlerOption {
+	return func(this *handler) {
+		this.maxBatch = value
+	}
+}
+
+// limitBatch caps the upper sequence of a batch, zero means no limit
+func limitBatch(lower, upper, maxBatch int64) int64 {
+	if maxBatch > 0 && upper-lower >= maxBatch {
+		return lower + maxBatch - 1
+	}
+	return upper
+}
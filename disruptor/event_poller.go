@@ -0,0 +1,55 @@
+package disruptor
+
+type PollState int
+
+const (
+	PollProcessing PollState = iota // a batch of sequences has been consumed
+	PollGating                      // sequences have been written but the upstream handlers have not processed them yet
+	PollIdle                        // nothing has been written since the last poll
+)
+
+// EventPoller is a reader which is driven by the caller instead of a goroutine of its own,
+// e.g. once per tick of an event loop. It must be wired with WithEventPoller and polled
+// from one goroutine at a time.
+type EventPoller struct {
+	current		*Cursor // this poller has processed up to this sequence
+	written		*Cursor // the ring buffer has been written up to this sequence
+	upstream	Barrier // all of the upstream handlers have advanced up to this sequence
+	metrics		*readerMetrics
+	maxBatch	int64
+}
+
+func NewEventPoller() *EventPoller {
+	return &EventPoller{current: NewCursor(), metrics: &readerMetrics{}}
+}
+
+// Poll hands the sequences which are available to the consumer in one batch, limited by
+// MaxBatchSize, and reports why it stopped
+func (this *EventPoller) Poll(consumer Consumer) PollState {
+	lower := this.current.Load() + 1
+
+	if upper := this.upstream.Load(); lower <= upper {
+		upper = limitBatch(lower, upper, this.maxBatch)
+		consumer.Consume(lower, upper)
+		this.current.Store(upper)
+		this.metrics.batch(lower, upper)
+		return PollProcessing
+	} else if upper = this.written.Load(); lower <= upper {
+		this.metrics.gate()
+		return PollGating
+	} else {
+		this.metrics.idle()
+		return PollIdle
+	}
+}
+
+func (this PollState) String() string {
+	switch this {
+	case PollProcessing:
+		return "processing"
+	case PollGating:
+		return "gating"
+	default:
+		return "idle"
+	}
+}
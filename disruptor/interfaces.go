@@ -26,3 +26,6 @@ type Reader interface {
 }
 
 var ErrMinimumReservationSize = errors.New("the minimum reservation size is 1 slot")
+
+// ErrClosed is the panic value of Reserve once the disruptor has been shut down or halted
+var ErrClosed = errors.New("the disruptor no longer accepts reservations")
@@ -0,0 +1,294 @@
+package disruptor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errCorruptJournal = errors.New("the journal record does not match its checksum or sequence")
+
+const (
+	journalExtension      = ".journal"
+	journalHeaderSize     = 16 // payload length, checksum and sequence, the checksum covers the other two
+	defaultJournalSegment = 64 * 1024 * 1024
+)
+
+// Journal is a consumer which appends the payload of every sequence to segmented log files
+// and syncs them to disk once per batch. The journal numbers its records itself, starting
+// after the last record which is already on disk, so that sequences stay unique across restarts.
+// A failed write panics because the handlers after the journal must not see undurable events.
+type Journal struct {
+	directory	string
+	encode		func(sequence int64) []byte
+	segmentSize	int64
+	next		int64 // the journal sequence of the next record
+	offset		int64 // the journal sequence minus the ring buffer sequence
+	file		*os.File
+	buffer		*bufio.Writer
+	size		int64
+	header		[journalHeaderSize]byte
+}
+
+type JournalOption func(*Journal)
+
+// WithSegmentSize rolls over to a new segment file once the current one has reached the size in bytes
+func WithSegmentSize(value int64) JournalOption {
+	return func(this *Journal) {
+		this.segmentSize = value
+	}
+}
+
+// NewJournal opens the journal in the directory, encode returns the payload stored in the ring
+// buffer at the sequence. A record torn by a crash at the end of the last segment is truncated.
+func NewJournal(directory string, encode func(sequence int64) []byte, options ...JournalOption) (*Journal, error) {
+	this := &Journal{directory: directory, encode: encode, segmentSize: defaultJournalSegment, offset: -1}
+	for _, option := range options {
+		option(this)
+	}
+
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, err
+	}
+
+	segments, err := journalSegments(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) > 0 {
+		last := segments[len(segments)-1]
+		if this.next, err = recoverSegment(filepath.Join(directory, last.name), last.first); err != nil {
+			return nil, err
+		}
+	}
+
+	return this, nil
+}
+
+func (this *Journal) Consume(lower, upper int64) {
+	if this.offset < 0 {
+		this.offset = this.next - lower
+	}
+
+	for sequence := lower; sequence <= upper; sequence++ {
+		if err := this.append(sequence+this.offset, this.encode(sequence)); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := this.sync(); err != nil {
+		panic(err)
+	}
+}
+
+func (this *Journal) append(sequence int64, payload []byte) error {
+	if this.file == nil || this.size >= this.segmentSize {
+		if err := this.roll(sequence); err != nil {
+			return err
+		}
+	}
+
+	binary.LittleEndian.PutUint32(this.header[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint64(this.header[8:16], uint64(sequence))
+	binary.LittleEndian.PutUint32(this.header[4:8], journalChecksum(this.header, payload))
+
+	if _, err := this.buffer.Write(this.header[:]); err != nil {
+		return err
+	}
+	if _, err := this.buffer.Write(payload); err != nil {
+		return err
+	}
+
+	this.size += int64(journalHeaderSize + len(payload))
+	this.next = sequence + 1
+	return nil
+}
+
+// roll closes the current segment and starts a new one named after its first sequence
+func (this *Journal) roll(first int64) error {
+	if err := this.closeSegment(); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(this.directory, segmentName(first)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	this.file = file
+	this.buffer = bufio.NewWriter(file)
+	this.size = 0
+	return nil
+}
+
+func (this *Journal) sync() error {
+	if this.file == nil {
+		return nil
+	}
+	if err := this.buffer.Flush(); err != nil {
+		return err
+	}
+	return this.file.Sync()
+}
+
+func (this *Journal) closeSegment() error {
+	if this.file == nil {
+		return nil
+	}
+	if err := this.sync(); err != nil {
+		return err
+	}
+	err := this.file.Close()
+	this.file, this.buffer = nil, nil
+	return err
+}
+
+// Close is called by the reader once it has exited
+func (this *Journal) Close() error {
+	return this.closeSegment()
+}
+
+// Replay publishes every journaled payload from the journal sequence onwards through the writer,
+// decode copies the payload into the ring buffer slot of the reserved sequence. The pipeline must
+// not journal into the same directory. It returns how many records were replayed.
+func Replay(directory string, from int64, writer Writer, decode func(sequence int64, payload []byte)) (int64, error) {
+	segments, err := journalSegments(directory)
+	if err != nil {
+		return 0, err
+	}
+
+	var replayed int64
+	for i, segment := range segments {
+		if i+1 < len(segments) && segments[i+1].first <= from {
+			continue
+		}
+
+		err := readSegment(filepath.Join(directory, segment.name), segment.first, func(sequence int64, payload []byte) {
+			if sequence < from {
+				return
+			}
+			reserved := writer.Reserve(1)
+			decode(reserved, payload)
+			writer.Commit(reserved, reserved)
+			replayed++
+		})
+
+		// only the last segment can end with a record torn by a crash, NewJournal truncates it
+		if i == len(segments)-1 && tornTail(err) {
+			err = nil
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("%s: %w", segment.name, err)
+		}
+	}
+
+	return replayed, nil
+}
+
+type journalSegment struct {
+	name	string
+	first	int64
+}
+
+func segmentName(first int64) string {
+	return fmt.Sprintf("%020d%s", first, journalExtension)
+}
+
+func journalSegments(directory string) ([]journalSegment, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []journalSegment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, journalExtension) {
+			continue
+		}
+		first, err := strconv.ParseInt(strings.TrimSuffix(name, journalExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, journalSegment{name: name, first: first})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].first < segments[j].first })
+	return segments, nil
+}
+
+// journalChecksum covers the payload length and sequence of the header besides the payload, so
+// that a zero filled tail is not taken for an empty record
+func journalChecksum(header [journalHeaderSize]byte, payload []byte) uint32 {
+	checksum := crc32.ChecksumIEEE(header[0:4])
+	checksum = crc32.Update(checksum, crc32.IEEETable, header[8:16])
+	return crc32.Update(checksum, crc32.IEEETable, payload)
+}
+
+// tornTail reports whether readSegment stopped at a record which a crash left incomplete
+func tornTail(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errCorruptJournal)
+}
+
+// readSegment hands every intact record of the segment to the callback, the records have to
+// follow each other from the first sequence of the segment. It stops with io.ErrUnexpectedEOF
+// or errCorruptJournal at the first record which is incomplete.
+func readSegment(path string, first int64, callback func(sequence int64, payload []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader = bufio.NewReader(file)
+	var header [journalHeaderSize]byte
+
+	for {
+		if _, err := io.ReadFull(reader, header[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		payload := make([]byte, binary.LittleEndian.Uint32(header[0:4]))
+		if _, err := io.ReadFull(reader, payload); err == io.EOF {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+		if journalChecksum(header, payload) != binary.LittleEndian.Uint32(header[4:8]) {
+			return errCorruptJournal
+		}
+		sequence := int64(binary.LittleEndian.Uint64(header[8:16]))
+		if sequence != first {
+			return errCorruptJournal
+		}
+
+		callback(sequence, payload)
+		first++
+	}
+}
+
+// recoverSegment truncates the segment after its last intact record and returns the sequence after it
+func recoverSegment(path string, first int64) (int64, error) {
+	var next, size = first, int64(0)
+
+	err := readSegment(path, first, func(sequence int64, payload []byte) {
+		next = sequence + 1
+		size += int64(journalHeaderSize + len(payload))
+	})
+	if tornTail(err) {
+		err = os.Truncate(path, size)
+	}
+
+	return next, err
+}
@@ -0,0 +1,121 @@
+package disruptor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalReplay(t *testing.T) {
+	const capacity = 16
+	directory := t.TempDir()
+
+	var ring [capacity]string
+	journal, err := NewJournal(directory, func(sequence int64) []byte {
+		return []byte(ring[sequence&(capacity-1)])
+	}, WithSegmentSize(256))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	myDisruptor := New(WithCapacity(capacity), WithHandler("journal", journal))
+	go myDisruptor.Read()
+	for i := 0; i < 100; i++ {
+		sequence := myDisruptor.Reserve(1)
+		ring[sequence&(capacity-1)] = fmt.Sprintf("event-%d", sequence)
+		myDisruptor.Commit(sequence, sequence)
+	}
+	if _, err := myDisruptor.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if segments, _ := os.ReadDir(directory); len(segments) < 2 {
+		t.Fatalf("expected the journal to roll over to several segments, got %d", len(segments))
+	}
+
+	// a torn record at the end of the journal is ignored
+	segments, _ := journalSegments(directory)
+	last, _ := os.OpenFile(filepath.Join(directory, segments[len(segments)-1].name), os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = last.Write([]byte{42, 0, 0, 0})
+	_ = last.Close()
+
+	var replayed []string
+	replay := New(WithCapacity(capacity), WithHandler("apply", &countingConsumer{}))
+	go replay.Read()
+	count, err := Replay(directory, 90, replay, func(sequence int64, payload []byte) {
+		replayed = append(replayed, string(payload))
+	})
+	if err != nil || count != 10 {
+		t.Fatalf("expected 10 replayed records, got %d and %v", count, err)
+	}
+	if replayed[0] != "event-90" || replayed[9] != "event-99" {
+		t.Fatalf("unexpected replayed payloads %v", replayed)
+	}
+	_, _ = replay.Shutdown(context.Background())
+
+	// a reopened journal truncates the torn record and continues with the next sequence
+	reopened, err := NewJournal(directory, func(int64) []byte { return []byte("again") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.Consume(0, 0)
+	_ = reopened.Close()
+
+	replayed = nil
+	check := New(WithCapacity(capacity), WithHandler("apply", &countingConsumer{}))
+	go check.Read()
+	count, err = Replay(directory, 100, check, func(sequence int64, payload []byte) {
+		replayed = append(replayed, string(payload))
+	})
+	if err != nil || count != 1 || replayed[0] != "again" {
+		t.Fatalf("expected the record after the restart to be numbered 100, got %v and %v", replayed, err)
+	}
+	_, _ = check.Shutdown(context.Background())
+}
+
+func TestJournalTornTail(t *testing.T) {
+	for name, tail := range map[string]func(path string){
+		// a crash can leave a zero filled tail behind, it must not parse as an empty record
+		"zeros": func(path string) {
+			file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			_, _ = file.Write(make([]byte, 2*journalHeaderSize))
+			_ = file.Close()
+		},
+		"corrupt record": func(path string) {
+			data, _ := os.ReadFile(path)
+			data[len(data)-1] ^= 0xff
+			_ = os.WriteFile(path, data, 0o644)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			directory := t.TempDir()
+			journal, err := NewJournal(directory, func(sequence int64) []byte { return []byte{byte(sequence)} })
+			if err != nil {
+				t.Fatal(err)
+			}
+			journal.Consume(0, 9)
+			_ = journal.Close()
+			segments, _ := journalSegments(directory)
+			tail(filepath.Join(directory, segments[0].name))
+
+			replay := New(WithCapacity(16), WithHandler("apply", &countingConsumer{}))
+			go replay.Read()
+			count, err := Replay(directory, 0, replay, func(int64, []byte) {})
+			_, _ = replay.Shutdown(context.Background())
+
+			reopened, err2 := NewJournal(directory, func(int64) []byte { return nil })
+			if err2 != nil {
+				t.Fatal(err2)
+			}
+			// Replay and NewJournal agree on where the journal ends
+			if err != nil || count != reopened.next {
+				t.Fatalf("expected Replay to stop where the journal continues at %d, got %d and %v", reopened.next, count, err)
+			}
+			if name == "zeros" && reopened.next != 10 {
+				t.Fatalf("expected the journal to continue at 10, got %d", reopened.next)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package disruptor
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrTranslatorPanicked = errors.New("the event translator panicked")
+
+// RingBuffer holds the events of a disruptor, its capacity must match the capacity of the disruptor
+type RingBuffer[T any] struct {
+	slots	[]T
+	mask	int64
+}
+
+func NewRingBuffer[T any](capacity int64) *RingBuffer[T] {
+	if capacity <= 0 {
+		panic(errCapacityTooSmall)
+	}
+	if capacity&(capacity-1) != 0 {
+		panic(errCapacityPowerOfTwo)
+	}
+	return &RingBuffer[T]{slots: make([]T, capacity), mask: capacity - 1}
+}
+
+// Get returns the slot which holds the sequence
+func (this *RingBuffer[T]) Get(sequence int64) *T {
+	return &this.slots[sequence&this.mask]
+}
+
+// rollbacker is implemented by writers which can give back their latest reservation
+type rollbacker interface {
+	rollback(lower, upper int64)
+}
+
+// Publisher reserves, fills and commits slots of a ring buffer in one call. Like the writer
+// it wraps, it must only be used by one goroutine at a time.
+type Publisher[T any] struct {
+	writer	Writer
+	ring	*RingBuffer[T]
+}
+
+func NewPublisher[T any](writer Writer, ring *RingBuffer[T]) *Publisher[T] {
+	if myDisruptor, ok := writer.(Disruptor); ok {
+		writer = myDisruptor.Writer
+	}
+	return &Publisher[T]{writer: writer, ring: ring}
+}
+
+// Publish reserves one slot, lets the translator fill it and commits it
+func (this *Publisher[T]) Publish(translator func(sequence int64, slot *T)) (int64, error) {
+	return this.PublishBatch(1, translator)
+}
+
+// PublishBatch reserves count slots, calls the translator for every slot and commits them
+// together. When the translator panics the reservation is given back, or the slots are reset
+// to their zero value and committed if the writer cannot give it back, so that no gap is left.
+func (this *Publisher[T]) PublishBatch(count int64, translator func(sequence int64, slot *T)) (upper int64, err error) {
+	if upper, err = this.reserve(count); err != nil {
+		return upper, err
+	}
+	lower := upper - count + 1
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%w: %v", ErrTranslatorPanicked, recovered)
+
+			if writer, ok := this.writer.(rollbacker); ok {
+				writer.rollback(lower, upper)
+				return
+			}
+
+			var empty T
+			for sequence := lower; sequence <= upper; sequence++ {
+				*this.ring.Get(sequence) = empty
+			}
+			this.writer.Commit(lower, upper)
+		}
+	}()
+
+	for sequence := lower; sequence <= upper; sequence++ {
+		translator(sequence, this.ring.Get(sequence))
+	}
+	this.writer.Commit(lower, upper)
+
+	return upper, nil
+}
+
+// reserve turns the panics of Reserve into errors
+func (this *Publisher[T]) reserve(count int64) (upper int64, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if recoveredErr, ok := recovered.(error); ok && (recoveredErr == ErrClosed || recoveredErr == ErrMinimumReservationSize) {
+				upper, err = defaultCursorValue, recoveredErr
+				return
+			}
+			panic(recovered)
+		}
+	}()
+
+	return this.writer.Reserve(count), nil
+}
@@ -0,0 +1,61 @@
+package disruptor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+type event struct {
+	sequence int64
+}
+
+// slotConsumer fails when a slot does not hold the sequence it was published for
+type slotConsumer struct {
+	t    *testing.T
+	ring *RingBuffer[event]
+	countingConsumer
+}
+
+func (this *slotConsumer) Consume(lower, upper int64) {
+	for sequence := lower; sequence <= upper; sequence++ {
+		if value := this.ring.Get(sequence).sequence; value != sequence {
+			this.t.Errorf("expected slot %d to hold its sequence, got %d", sequence, value)
+		}
+	}
+	this.countingConsumer.Consume(lower, upper)
+}
+
+func TestPublisherRecoversPanickingTranslator(t *testing.T) {
+	ring := NewRingBuffer[event](16)
+	consumer := &slotConsumer{t: t, ring: ring}
+	myDisruptor := New(WithCapacity(16), WithHandler("check", consumer))
+	publisher := NewPublisher[event](myDisruptor, ring)
+	go myDisruptor.Read()
+
+	fill := func(sequence int64, slot *event) { slot.sequence = sequence }
+
+	for i := 0; i < 100; i++ {
+		if _, err := publisher.PublishBatch(3, fill); err != nil {
+			t.Fatal(err)
+		}
+		_, err := publisher.Publish(func(sequence int64, slot *event) {
+			slot.sequence = -1
+			panic("boom")
+		})
+		if !errors.Is(err, ErrTranslatorPanicked) {
+			t.Fatalf("expected the panic to be reported, got %v", err)
+		}
+	}
+
+	if _, err := myDisruptor.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if count := atomic.LoadInt64(&consumer.count); count != 300 {
+		t.Fatalf("expected 300 events, got %d", count)
+	}
+	if _, err := publisher.Publish(fill); err != ErrClosed {
+		t.Fatalf("expected publishing after shutdown to fail, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+//go:build linux
+
+package disruptor
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	errSharedRingLayout  = errors.New("the file is not a shared ring buffer of a known layout version")
+	errSharedSlotSize    = errors.New("the slot size must be at least 1 byte")
+	errSharedReaderCount = errors.New("a shared ring buffer must have at least 1 reader")
+	errSharedReaderIndex = errors.New("the shared ring buffer does not have a reader at this index")
+	errSharedRingSize    = errors.New("the slots of the shared ring buffer do not fit into a file")
+)
+
+const (
+	sharedMagic         = 0x52494e4744535255
+	sharedLayoutVersion = 1
+	cacheLineSize       = int64(unsafe.Sizeof(Cursor{}))
+)
+
+// SharedRing is a ring buffer whose slots and cursors live in a memory mapped file so that a writer
+// and its readers can run in separate processes on the same host. The file starts with a header
+// cache line holding the magic number, layout version, reader count, capacity and slot size,
+// followed by the written cursor, one cursor per reader and finally the slots.
+type SharedRing struct {
+	file		*os.File
+	memory		[]byte
+	capacity	int64
+	slotSize	int64
+	written		*Cursor
+	sequences	[]*Cursor
+	slots		[]byte
+}
+
+// CreateSharedRing creates or replaces the file with an empty ring buffer for a fixed number of readers
+func CreateSharedRing(path string, capacity, slotSize int64, readers int) (*SharedRing, error) {
+	if err := validateSharedLayout(capacity, slotSize, readers); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err = file.Truncate(sharedFileSize(capacity, slotSize, readers)); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	this, err := mapSharedRing(file, capacity, slotSize, readers)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.LittleEndian.PutUint32(this.memory[8:12], sharedLayoutVersion)
+	binary.LittleEndian.PutUint32(this.memory[12:16], uint32(readers))
+	binary.LittleEndian.PutUint64(this.memory[16:24], uint64(capacity))
+	binary.LittleEndian.PutUint64(this.memory[24:32], uint64(slotSize))
+	this.written.Store(defaultCursorValue)
+	for _, item := range this.sequences {
+		item.Store(defaultCursorValue)
+	}
+
+	// the magic number is stored last, a process opening the file early sees an unknown layout
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(&this.memory[0])), sharedMagic)
+	return this, nil
+}
+
+// OpenSharedRing maps a ring buffer which has been created by another process
+func OpenSharedRing(path string) (*SharedRing, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var header [cacheLineSize]byte
+	if _, err = file.ReadAt(header[:], 0); err != nil {
+		_ = file.Close()
+		return nil, errSharedRingLayout
+	}
+	if binary.LittleEndian.Uint64(header[0:8]) != sharedMagic || binary.LittleEndian.Uint32(header[8:12]) != sharedLayoutVersion {
+		_ = file.Close()
+		return nil, errSharedRingLayout
+	}
+
+	readers := int(binary.LittleEndian.Uint32(header[12:16]))
+	capacity := int64(binary.LittleEndian.Uint64(header[16:24]))
+	slotSize := int64(binary.LittleEndian.Uint64(header[24:32]))
+
+	// the header is not trusted, a bad capacity breaks the slot mask and a huge one the size check
+	if err = validateSharedLayout(capacity, slotSize, readers); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || info.Size() < sharedFileSize(capacity, slotSize, readers) {
+		_ = file.Close()
+		return nil, errSharedRingLayout
+	}
+
+	return mapSharedRing(file, capacity, slotSize, readers)
+}
+
+func mapSharedRing(file *os.File, capacity, slotSize int64, readers int) (*SharedRing, error) {
+	memory, err := syscall.Mmap(int(file.Fd()), 0, int(sharedFileSize(capacity, slotSize, readers)), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	this := &SharedRing{
+		file:     file,
+		memory:   memory,
+		capacity: capacity,
+		slotSize: slotSize,
+		written:  sharedCursor(memory, 1),
+		slots:    memory[sharedSlotsOffset(readers):],
+	}
+	for i := 0; i < readers; i++ {
+		this.sequences = append(this.sequences, sharedCursor(memory, 2+i))
+	}
+
+	return this, nil
+}
+
+// sharedCursor points into the cache line at the index, the mapping is page aligned
+func sharedCursor(memory []byte, line int) *Cursor {
+	return (*Cursor)(unsafe.Pointer(&memory[int64(line)*cacheLineSize]))
+}
+
+func validateSharedLayout(capacity, slotSize int64, readers int) error {
+	if capacity <= 0 {
+		return errCapacityTooSmall
+	}
+	if capacity&(capacity-1) != 0 {
+		return errCapacityPowerOfTwo
+	}
+	if slotSize <= 0 {
+		return errSharedSlotSize
+	}
+	if readers <= 0 {
+		return errSharedReaderCount
+	}
+	if capacity > (math.MaxInt64-sharedSlotsOffset(readers))/slotSize {
+		return errSharedRingSize
+	}
+	return nil
+}
+
+func sharedSlotsOffset(readers int) int64 {
+	return int64(2+readers) * cacheLineSize
+}
+
+func sharedFileSize(capacity, slotSize int64, readers int) int64 {
+	return sharedSlotsOffset(readers) + capacity*slotSize
+}
+
+func (this *SharedRing) Capacity() int64 {
+	return this.capacity
+}
+
+// Slot returns the bytes of the slot which holds the sequence
+func (this *SharedRing) Slot(sequence int64) []byte {
+	lower := (sequence & (this.capacity - 1)) * this.slotSize
+	return this.slots[lower : lower+this.slotSize : lower+this.slotSize]
+}
+
+// Writer reserves slots behind all readers of the ring, there must only be one writer at a time.
+// It continues after the sequence which has been written last, e.g. by a previous process.
+func (this *SharedRing) Writer() Writer {
+	writer := NewWriter(this.written, NewCompositeBarrier(this.sequences...), this.capacity)
+	writer.previous = this.written.Load()
+	writer.gate = writer.upstream.Load()
+	return writer
+}
+
+// Reader consumes the ring with the cursor at the index, it continues after the sequence it has
+// processed last
+func (this *SharedRing) Reader(index int, waiter WaitStrategy, consumer Consumer) (Reader, error) {
+	if index < 0 || index >= len(this.sequences) {
+		return nil, errSharedReaderIndex
+	}
+	return NewReader(this.sequences[index], this.written, this.written, waiter, consumer), nil
+}
+
+// Close unmaps the ring buffer, it must not be used by a writer or reader afterwards
+func (this *SharedRing) Close() error {
+	err := syscall.Munmap(this.memory)
+	if closeErr := this.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+//go:build linux
+
+package disruptor
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sharedConsumer checks that every slot of the other mapping holds its own sequence
+type sharedConsumer struct {
+	t    *testing.T
+	ring *SharedRing
+	countingConsumer
+}
+
+func (this *sharedConsumer) Consume(lower, upper int64) {
+	for sequence := lower; sequence <= upper; sequence++ {
+		if value := int64(binary.LittleEndian.Uint64(this.ring.Slot(sequence))); value != sequence {
+			this.t.Errorf("expected slot %d to hold its sequence, got %d", sequence, value)
+		}
+	}
+	this.countingConsumer.Consume(lower, upper)
+}
+
+func TestSharedRing(t *testing.T) {
+	const iterations = 1024 * 16
+	path := filepath.Join(t.TempDir(), "ring")
+
+	producer, err := CreateSharedRing(path, 64, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	// the consumer maps the file a second time, just like another process would
+	consumer, err := OpenSharedRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	checker := &sharedConsumer{t: t, ring: consumer}
+	reader, err := consumer.Reader(0, NewWaitStrategy(), checker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		writer := producer.Writer()
+		for i := 0; i < iterations; i++ {
+			sequence := writer.Reserve(1)
+			binary.LittleEndian.PutUint64(producer.Slot(sequence), uint64(sequence))
+			writer.Commit(sequence, sequence)
+		}
+		_ = reader.Close()
+	}()
+
+	reader.Read()
+
+	if checker.count != iterations {
+		t.Fatalf("expected %d events, got %d", iterations, checker.count)
+	}
+	if _, err := consumer.Reader(1, NewWaitStrategy(), checker); err == nil {
+		t.Fatal("expected a reader index beyond the header to fail")
+	}
+}
+
+func TestOpenSharedRingValidatesHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	ring, err := CreateSharedRing(path, 64, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ring.Close()
+
+	for _, corrupt := range []struct {
+		capacity, slotSize uint64
+		expected           error
+	}{
+		{0, 8, errCapacityTooSmall},
+		{48, 8, errCapacityPowerOfTwo},
+		{64, 0, errSharedSlotSize},
+		// the product wraps around to a size which the file would satisfy
+		{1 << 62, 1 << 2, errSharedRingSize},
+	} {
+		file, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var header [16]byte
+		binary.LittleEndian.PutUint64(header[0:8], corrupt.capacity)
+		binary.LittleEndian.PutUint64(header[8:16], corrupt.slotSize)
+		_, err = file.WriteAt(header[:], 16)
+		_ = file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := OpenSharedRing(path); err != corrupt.expected {
+			t.Fatalf("expected %v for capacity %d and slot size %d, got %v", corrupt.expected, corrupt.capacity, corrupt.slotSize, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package disruptor
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const batchBuckets = 32
+
+// BatchHistogram counts the batches handed to a consumer, bucket i holds the batches
+// of 2^i up to 2^(i+1)-1 sequences
+type BatchHistogram [batchBuckets]uint64
+
+type ReaderStats struct {
+	Name		string
+	Sequence	int64 // the reader has processed up to this sequence, the slowest worker for a worker pool
+	Lag		int64 // how many written sequences the reader has not processed yet
+	Batches		BatchHistogram
+	Gates		uint64 // how often the reader waited on an upstream handler
+	Idles		uint64 // how often the reader waited on the writer
+}
+
+type Stats struct {
+	Written	int64
+	Readers	[]ReaderStats
+}
+
+// readerMetrics is updated by a reader while it runs and read by Stats at any time
+type readerMetrics struct {
+	gates		uint64
+	idles		uint64
+	batches		BatchHistogram
+}
+
+func (this *readerMetrics) batch(lower, upper int64) {
+	atomic.AddUint64(&this.batches[bits.Len64(uint64(upper-lower+1))-1], 1)
+}
+
+func (this *readerMetrics) gate() {
+	atomic.AddUint64(&this.gates, 1)
+}
+
+func (this *readerMetrics) idle() {
+	atomic.AddUint64(&this.idles, 1)
+}
+
+func (this *readerMetrics) snapshot(into *ReaderStats) {
+	into.Gates = atomic.LoadUint64(&this.gates)
+	into.Idles = atomic.LoadUint64(&this.idles)
+	for i := range this.batches {
+		into.Batches[i] = atomic.LoadUint64(&this.batches[i])
+	}
+}
+
+// WithStatsExporter calls the exporter with a snapshot of the statistics at every interval
+// while Read is running
+func WithStatsExporter(interval time.Duration, exporter func(Stats)) Option {
+	return func(this *Wireup) {
+		this.exporters = append(this.exporters, statsExporter{interval: interval, export: exporter})
+	}
+}
+
+type statsExporter struct {
+	interval	time.Duration
+	export		func(Stats)
+}
+
+func (this statsExporter) run(source func() Stats, stopped <-chan struct{}) {
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			this.export(source())
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// Count is the number of batches in the histogram
+func (this BatchHistogram) Count() (count uint64) {
+	for _, item := range this {
+		count += item
+	}
+	return count
+}
@@ -0,0 +1,328 @@
+package disruptor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrStaticTopology       = errors.New("the handlers can only be changed on a disruptor created by New")
+	errUnknownHandler       = errors.New("there is no handler with this name")
+	errHandlerHasDependents = errors.New("the handler cannot be detached while other handlers depend on it")
+	errLastHandler          = errors.New("the last handler cannot be detached, the writer would have nothing to wait on")
+)
+
+// node is a running handler of the consumer graph
+type node struct {
+	name		string
+	after		[]string
+	sequences	[]*Cursor
+	reader		Reader // nil for event pollers, they are driven by the caller
+	metrics		*readerMetrics
+	started		int32
+	done		chan struct{}
+}
+
+func (this *node) Read() {
+	atomic.StoreInt32(&this.started, 1)
+	defer close(this.done)
+	this.reader.Read()
+}
+
+func (this *node) Close() error {
+	return this.reader.Close()
+}
+
+func (this *node) halt() {
+	if reader, ok := this.reader.(halter); ok {
+		reader.halt()
+	} else {
+		_ = this.reader.Close()
+	}
+}
+
+// moveTo lets the handler start after the sequence, it must not be running yet
+func (this *node) moveTo(start int64) {
+	for _, item := range this.sequences {
+		item.Store(start)
+	}
+	if pool, ok := this.reader.(*workerPool); ok {
+		pool.work.Store(start)
+	}
+}
+
+// wait blocks until the reader has exited, unless it was never started
+func (this *node) wait() {
+	if this.reader != nil && atomic.LoadInt32(&this.started) == 1 {
+		<-this.done
+	}
+}
+
+// dynamicBarrier is the gating barrier of the writer, its sequences are replaced as a whole
+// whenever a handler is attached or detached
+type dynamicBarrier struct {
+	sequences atomic.Value // []*Cursor
+}
+
+func (this *dynamicBarrier) Load() int64 {
+	if sequences := this.sequences.Load().([]*Cursor); len(sequences) > 0 {
+		return compositeBarrier(sequences).Load()
+	}
+	return math.MaxInt64
+}
+
+func (this *dynamicBarrier) add(sequences ...*Cursor) {
+	current := this.sequences.Load().([]*Cursor)
+	updated := make([]*Cursor, 0, len(current)+len(sequences))
+	updated = append(updated, current...)
+	for _, item := range sequences {
+		if !containsCursor(updated, item) {
+			updated = append(updated, item)
+		}
+	}
+	this.sequences.Store(updated)
+}
+
+func (this *dynamicBarrier) remove(sequences ...*Cursor) {
+	var remaining []*Cursor
+	for _, item := range this.sequences.Load().([]*Cursor) {
+		if !containsCursor(sequences, item) {
+			remaining = append(remaining, item)
+		}
+	}
+	this.sequences.Store(remaining)
+}
+
+func containsCursor(sequences []*Cursor, sequence *Cursor) bool {
+	for _, item := range sequences {
+		if item == sequence {
+			return true
+		}
+	}
+	return false
+}
+
+// topology keeps track of the handlers of a disruptor so they can be attached and detached
+// while it is running
+type topology struct {
+	mutex		sync.Mutex
+	written		*Cursor
+	waiter		WaitStrategy
+	gate		*dynamicBarrier
+	nodes		map[string]*node
+	order		[]*node
+	attached	[]*node
+	dependents	map[string]int
+	exporters	[]statsExporter
+}
+
+func newTopology(written *Cursor, waiter WaitStrategy) *topology {
+	this := &topology{
+		written:    written,
+		waiter:     waiter,
+		gate:       &dynamicBarrier{},
+		nodes:      make(map[string]*node),
+		dependents: make(map[string]int),
+	}
+	this.gate.sequences.Store([]*Cursor(nil))
+	return this
+}
+
+// add creates the reader of the handler, it starts from the sequence which has been written last
+func (this *topology) add(item *handler) *node {
+	var start = this.written.Load()
+	var barrier Barrier = this.written
+
+	if len(item.after) > 0 {
+		var dependencies []*Cursor
+		for _, name := range item.after {
+			dependencies = append(dependencies, this.nodes[name].sequences...)
+			this.dependents[name]++
+		}
+		barrier = NewCompositeBarrier(dependencies...)
+	}
+
+	var created = &node{name: item.name, after: item.after, done: make(chan struct{})}
+
+	if item.poller != nil {
+		item.poller.current.Store(start)
+		item.poller.written = this.written
+		item.poller.upstream = barrier
+		item.poller.maxBatch = item.maxBatch
+		created.sequences = []*Cursor{item.poller.current}
+		created.metrics = item.poller.metrics
+	} else if item.pooled {
+		for i := 0; i < item.workers; i++ {
+			created.sequences = append(created.sequences, newCursorAt(start))
+		}
+		pool := newWorkerPool(created.sequences, this.written, barrier, this.waiter, item.consumer)
+		pool.work.Store(start)
+		created.reader = pool
+		created.metrics = pool.metrics
+	} else {
+		currentSequence := newCursorAt(start)
+		created.sequences = []*Cursor{currentSequence}
+		reader := NewReader(currentSequence, this.written, barrier, this.waiter, item.consumer)
+		reader.maxBatch = item.maxBatch
+		created.reader = reader
+		created.metrics = reader.metrics
+	}
+
+	this.nodes[item.name] = created
+	this.order = append(this.order, created)
+	return created
+}
+
+// gateTails makes the writer wait on the handlers at the end of the graph, every
+// other handler is already behind at least one of them
+func (this *topology) gateTails(order []*handler) {
+	for _, item := range order {
+		if this.dependents[item.name] == 0 {
+			this.gate.add(this.nodes[item.name].sequences...)
+		}
+	}
+}
+
+// attach validates and starts handlers on a running disruptor
+func (this *topology) attach(handlers []*handler) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for _, item := range handlers {
+		if err := item.validate(); err != nil {
+			return err
+		}
+		if item.name == "" {
+			return errEmptyHandlerName
+		}
+		if _, ok := this.nodes[item.name]; ok {
+			return fmt.Errorf("%w: %s", errDuplicateHandler, item.name)
+		}
+		for _, name := range item.after {
+			if _, ok := this.nodes[name]; !ok {
+				return fmt.Errorf("%w: %s after %s", errUnknownDependency, item.name, name)
+			}
+		}
+
+		created := this.add(item)
+
+		// The writer may have wrapped past the written cursor which add started from while the new
+		// sequences were not gated yet, it only overwrites slots up to the gate it has loaded last.
+		// Any gate loaded from now on includes the new sequences and any gate loaded before is
+		// behind the written cursor, so the handler is safe once it starts from the cursor again.
+		this.gate.add(created.sequences...)
+		created.moveTo(this.written.Load())
+
+		if created.reader != nil {
+			this.attached = append(this.attached, created)
+			atomic.StoreInt32(&created.started, 1)
+			go created.Read()
+		}
+	}
+
+	return nil
+}
+
+// detach stops the reader of the handler and removes its sequences from the gating barrier
+func (this *topology) detach(name string) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	removed, ok := this.nodes[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownHandler, name)
+	}
+	if this.dependents[name] > 0 {
+		return fmt.Errorf("%w: %s", errHandlerHasDependents, name)
+	}
+	// any other handler is a tail or runs before one, so the gating barrier never becomes empty
+	// and the writer never caches the unbounded gate of an empty barrier
+	if len(this.order) == 1 {
+		return fmt.Errorf("%w: %s", errLastHandler, name)
+	}
+
+	// the writer keeps waiting on the sequences until the reader has stopped using its slots
+	if removed.reader != nil {
+		removed.halt()
+		removed.wait()
+	}
+
+	// gate on the dependencies which end the graph now before letting go of the handler
+	for _, dependency := range removed.after {
+		if this.dependents[dependency]--; this.dependents[dependency] == 0 {
+			this.gate.add(this.nodes[dependency].sequences...)
+		}
+	}
+	this.gate.remove(removed.sequences...)
+
+	delete(this.nodes, name)
+	delete(this.dependents, name)
+	for i, item := range this.order {
+		if item == removed {
+			this.order = append(this.order[:i:i], this.order[i+1:]...)
+			break
+		}
+	}
+	for i, item := range this.attached {
+		if item == removed {
+			this.attached = append(this.attached[:i:i], this.attached[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (this *topology) closeAttached() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for _, item := range this.attached {
+		_ = item.Close()
+	}
+}
+
+func (this *topology) haltAttached() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for _, item := range this.attached {
+		item.halt()
+	}
+}
+
+func (this *topology) waitAttached() {
+	this.mutex.Lock()
+	attached := this.attached
+	this.mutex.Unlock()
+
+	for _, item := range attached {
+		item.wait()
+	}
+}
+
+// stats takes a snapshot of every handler in the order they were added
+func (this *topology) stats() Stats {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	var snapshot = Stats{Written: this.written.Load()}
+	for _, item := range this.order {
+		var reader = ReaderStats{Name: item.name, Sequence: NewCompositeBarrier(item.sequences...).Load()}
+		if reader.Lag = snapshot.Written - reader.Sequence; reader.Lag < 0 {
+			reader.Lag = 0
+		}
+		item.metrics.snapshot(&reader)
+		snapshot.Readers = append(snapshot.Readers, reader)
+	}
+	return snapshot
+}
+
+func newCursorAt(value int64) *Cursor {
+	this := NewCursor()
+	this.Store(value)
+	return this
+}
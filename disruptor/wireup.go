@@ -1,6 +1,10 @@
 package disruptor
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 
 var (
@@ -10,16 +14,49 @@ var (
 	errMissingConsumers        = errors.New("no consumers have been provided")
 	errMissingConsumersInGroup = errors.New("the consumer group does not have any consumers")
 	errEmptyConsumer           = errors.New("an empty consumer was specified in the consumer group")
+	errEmptyHandlerName        = errors.New("a handler must have a name")
+	errDuplicateHandler        = errors.New("the handler name has already been used")
+	errUnknownDependency       = errors.New("the handler depends on an unknown handler")
+	errCyclicDependency        = errors.New("the handler dependencies contain a cycle")
+	errWorkerPoolTooSmall      = errors.New("the worker pool must have at least 1 worker")
+	errMaxBatchSize            = errors.New("the maximum batch size must not be negative")
+	errStatsInterval           = errors.New("the stats export interval must be positive")
+	errReservedHandlerName     = errors.New("the handler name uses the prefix reserved for consumer groups")
+	errConsumerGroupAttach     = errors.New("consumer groups cannot be attached, use WithHandler and After")
 )
 
+// consumerGroupPrefix starts the names of the handlers created by WithConsumerGroup, the other
+// handlers must not use it so the names cannot collide
+const consumerGroupPrefix = "consumer-group:"
+
 type Wireup struct {
 	waiter		WaitStrategy
 	capacity	int64
-	consumerGroups	[][]Consumer
+	handlers	[]*handler
+	lastGroup	[]string // the handler names of the most recent consumer group
+	groups		int
+	emptyGroup	bool
+	exporters	[]statsExporter
+}
+
+// handler is a named node of the consumer graph, it only reads the sequences
+// which all of its dependencies have already processed
+type handler struct {
+	name		string
+	consumer	Consumer
+	after		[]string
+	pooled		bool // each sequence is handed to exactly one of the workers
+	grouped		bool // created by WithConsumerGroup
+	workers		int
+	poller		*EventPoller
+	maxBatch	int64
 }
 
 type Option func(*Wireup)
 
+// HandlerOption configures a single named handler
+type HandlerOption func(*handler)
+
 func WithWaitStrategy(value WaitStrategy) Option {
 	return func(this *Wireup) {
 		this.waiter = value
@@ -32,10 +69,83 @@ func WithCapacity(value int64) Option {
 	}
 }
 
+// WithConsumerGroup appends consumers which run in parallel and wait on the whole previous group
 func WithConsumerGroup(value ...Consumer) Option {
 	return func(this *Wireup) {
-		this.consumerGroups = append(this.consumerGroups, value)
+		if len(value) == 0 {
+			this.emptyGroup = true
+			return
+		}
+
+		var names []string
+		for i, consumer := range value {
+			name := fmt.Sprintf("%s%d:%d", consumerGroupPrefix, this.groups, i)
+			this.handlers = append(this.handlers, &handler{name: name, consumer: consumer, after: this.lastGroup, grouped: true})
+			names = append(names, name)
+		}
+
+		this.lastGroup = names
+		this.groups++
+	}
+}
+
+// WithHandler appends a named consumer, use After to declare the handlers it depends on.
+// A handler without dependencies reads straight behind the writer.
+func WithHandler(name string, consumer Consumer, options ...HandlerOption) Option {
+	return func(this *Wireup) {
+		item := &handler{name: name, consumer: consumer}
+		for _, option := range options {
+			option(item)
+		}
+		this.handlers = append(this.handlers, item)
+	}
+}
+
+// WithWorkerPool appends a named handler whose workers share the sequences, each
+// sequence is consumed exactly once by one of the workers. The workers call the
+// same consumer concurrently, one sequence per call. Downstream handlers and the
+// writer wait on the slowest worker.
+func WithWorkerPool(name string, workers int, consumer Consumer, options ...HandlerOption) Option {
+	return func(this *Wireup) {
+		WithHandler(name, consumer, options...)(this)
+		this.handlers[len(this.handlers)-1].pooled = true
+		this.handlers[len(this.handlers)-1].workers = workers
+	}
+}
+
+// WithEventPoller appends a named handler which is driven by calling Poll on the poller
+// instead of by Read
+func WithEventPoller(name string, poller *EventPoller, options ...HandlerOption) Option {
+	return func(this *Wireup) {
+		item := &handler{name: name, poller: poller}
+		for _, option := range options {
+			option(item)
+		}
+		this.handlers = append(this.handlers, item)
+	}
+}
+
+// After makes the handler wait until all of the named handlers have processed a sequence
+func After(names ...string) HandlerOption {
+	return func(this *handler) {
+		this.after = append(this.after, names...)
+	}
+}
+
+func (this *handler) validate() error {
+	if !this.grouped && strings.HasPrefix(this.name, consumerGroupPrefix) {
+		return fmt.Errorf("%w: %s", errReservedHandlerName, this.name)
+	}
+	if this.consumer == nil && this.poller == nil {
+		return errEmptyConsumer
+	}
+	if this.pooled && this.workers <= 0 {
+		return errWorkerPoolTooSmall
+	}
+	if this.maxBatch < 0 {
+		return errMaxBatchSize
 	}
+	return nil
 }
 
 func NewWireup(options ...Option) (*Wireup, error) {
@@ -58,7 +168,10 @@ func New(options ...Option) Disruptor {
 	if this, err := NewWireup(options...); err != nil {
 		panic(err)
 	} else {
-		return NewDisruptor(this.Build())
+		writer, reader, graph := this.build()
+		myDisruptor := NewDisruptor(writer, reader)
+		myDisruptor.topology = graph
+		return myDisruptor
 	}
 }
 
@@ -75,45 +188,109 @@ func (this *Wireup) validate() error {
 		return errCapacityPowerOfTwo
 	}
 
-	if len(this.consumerGroups) == 0 {
+	if this.emptyGroup {
+		return errMissingConsumersInGroup
+	}
+
+	for _, exporter := range this.exporters {
+		if exporter.interval <= 0 || exporter.export == nil {
+			return errStatsInterval
+		}
+	}
+
+	if len(this.handlers) == 0 {
 		return errMissingConsumers
 	}
 
-	for _, consumerGroup := range this.consumerGroups {
-		if len(consumerGroup) == 0 {
-			return errMissingConsumersInGroup
+	for _, item := range this.handlers {
+		if err := item.validate(); err != nil {
+			return err
+		}
+	}
+
+	_, err := this.sortHandlers()
+	return err
+}
+
+// sortHandlers orders the handlers so that every handler comes after all of its dependencies,
+// handlers without a dependency between them keep their declaration order
+func (this *Wireup) sortHandlers() ([]*handler, error) {
+	var byName = make(map[string]*handler, len(this.handlers))
+	var pending = make(map[string]int, len(this.handlers))
+	var dependents = make(map[string][]string, len(this.handlers))
+
+	for _, item := range this.handlers {
+		if item.name == "" {
+			return nil, errEmptyHandlerName
 		}
+		if _, ok := byName[item.name]; ok {
+			return nil, fmt.Errorf("%w: %s", errDuplicateHandler, item.name)
+		}
+		byName[item.name] = item
+	}
 
-		for _, consumer := range consumerGroup {
-			if consumer == nil {
-				return errEmptyConsumer
+	for _, item := range this.handlers {
+		for _, name := range item.after {
+			if _, ok := byName[name]; !ok {
+				return nil, fmt.Errorf("%w: %s after %s", errUnknownDependency, item.name, name)
 			}
+			pending[item.name]++
+			dependents[name] = append(dependents[name], item.name)
 		}
 	}
 
-	return nil
+	var sorted = make([]*handler, 0, len(this.handlers))
+	var ready []string
+	for _, item := range this.handlers {
+		if pending[item.name] == 0 {
+			ready = append(ready, item.name)
+		}
+	}
+
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, byName[name])
+
+		for _, dependent := range dependents[name] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(sorted) != len(this.handlers) {
+		for _, item := range this.handlers {
+			if pending[item.name] > 0 {
+				return nil, fmt.Errorf("%w: %s", errCyclicDependency, item.name)
+			}
+		}
+	}
+
+	return sorted, nil
 }
 
 func (this *Wireup) Build() (Writer, Reader) {
-	var writeSequence = NewCursor()
-	readers, readBarrier := this.buildReaders(writeSequence)
-	return NewWriter(writeSequence, readBarrier, this.capacity), compositeReader(readers)
+	writer, reader, _ := this.build()
+	return writer, reader
 }
 
-func (this *Wireup) buildReaders(writerSequence *Cursor) (readers []Reader, upstream Barrier) {
-	upstream = writerSequence
+func (this *Wireup) build() (Writer, Reader, *topology) {
+	sorted, err := this.sortHandlers()
+	if err != nil {
+		panic(err)
+	}
 
-	for _, consumerGroup := range this.consumerGroups {
-		var consumerGroupSequences []*Cursor
+	var graph = newTopology(NewCursor(), this.waiter)
+	graph.exporters = this.exporters
+	var readers []Reader
 
-		for _, consumer := range consumerGroup {
-			currentSequence := NewCursor()
-			readers = append(readers, NewReader(currentSequence, writerSequence, upstream, this.waiter, consumer))
-			consumerGroupSequences = append(consumerGroupSequences, currentSequence)
+	for _, item := range sorted {
+		if created := graph.add(item); created.reader != nil {
+			readers = append(readers, created)
 		}
-
-		upstream = NewCompositeBarrier(consumerGroupSequences...)
 	}
-	return readers, upstream
-}
+	graph.gateTails(sorted)
 
+	return NewWriter(graph.written, graph.gate, this.capacity), compositeReader(readers), graph
+}
@@ -0,0 +1,145 @@
+package disruptor
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+type countingConsumer struct {
+	count int64
+}
+
+func (this *countingConsumer) Consume(lower, upper int64) {
+	atomic.AddInt64(&this.count, upper-lower+1)
+}
+
+func TestWireupRejectsCycles(t *testing.T) {
+	_, err := NewWireup(
+		WithCapacity(8),
+		WithHandler("a", &countingConsumer{}, After("c")),
+		WithHandler("b", &countingConsumer{}, After("a")),
+		WithHandler("c", &countingConsumer{}, After("b")),
+	)
+	if !errors.Is(err, errCyclicDependency) {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+}
+
+func TestWireupRejectsUnknownDependency(t *testing.T) {
+	_, err := NewWireup(WithCapacity(8), WithHandler("a", &countingConsumer{}, After("missing")))
+	if !errors.Is(err, errUnknownDependency) {
+		t.Fatalf("expected an unknown dependency error, got %v", err)
+	}
+}
+
+func TestWireupConsumerGroupNames(t *testing.T) {
+	// the names of group handlers do not collide with the names users choose
+	if _, err := NewWireup(WithCapacity(8), WithConsumerGroup(&countingConsumer{}), WithHandler("group-0-0", &countingConsumer{})); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewWireup(WithCapacity(8), WithConsumerGroup(&countingConsumer{}), WithHandler(consumerGroupPrefix+"0:0", &countingConsumer{}))
+	if !errors.Is(err, errReservedHandlerName) {
+		t.Fatalf("expected the reserved prefix to be rejected, got %v", err)
+	}
+
+	myDisruptor := New(WithCapacity(8), WithConsumerGroup(&countingConsumer{}))
+	if err := myDisruptor.Attach(WithConsumerGroup(&countingConsumer{})); !errors.Is(err, errConsumerGroupAttach) {
+		t.Fatalf("expected attaching a consumer group to fail, got %v", err)
+	}
+	if err := myDisruptor.Attach(WithHandler(consumerGroupPrefix+"1:0", &countingConsumer{})); !errors.Is(err, errReservedHandlerName) {
+		t.Fatalf("expected the reserved prefix to be rejected, got %v", err)
+	}
+}
+
+// orderedConsumer fails when it observes a sequence its dependencies have not processed yet
+type orderedConsumer struct {
+	t            *testing.T
+	dependencies []*countingConsumer
+	countingConsumer
+}
+
+func (this *orderedConsumer) Consume(lower, upper int64) {
+	for _, dependency := range this.dependencies {
+		if processed := atomic.LoadInt64(&dependency.count); processed <= upper {
+			this.t.Errorf("consumed %d before the dependency processed it (%d)", upper, processed-1)
+		}
+	}
+	this.countingConsumer.Consume(lower, upper)
+}
+
+func TestWireupDiamond(t *testing.T) {
+	const iterations = 1024 * 16
+
+	journal := &countingConsumer{}
+	replicate := &countingConsumer{}
+	business := &orderedConsumer{t: t, dependencies: []*countingConsumer{journal, replicate}}
+	audit := &orderedConsumer{t: t, dependencies: []*countingConsumer{journal}}
+
+	myDisruptor := New(
+		WithCapacity(64),
+		WithHandler("journal", journal),
+		WithHandler("replicate", replicate),
+		WithHandler("business", business, After("journal", "replicate")),
+		WithHandler("audit", audit, After("journal")),
+	)
+
+	go func() {
+		for sequence := int64(0); sequence < iterations-1; {
+			sequence = myDisruptor.Reserve(1)
+			myDisruptor.Commit(sequence, sequence)
+		}
+		_ = myDisruptor.Close()
+	}()
+
+	myDisruptor.Read()
+
+	for _, count := range []int64{journal.count, replicate.count, business.count, audit.count} {
+		if count != iterations {
+			t.Fatalf("expected %d events, got %d", iterations, count)
+		}
+	}
+}
+
+// exclusiveConsumer fails when a sequence is consumed more than once
+type exclusiveConsumer struct {
+	t    *testing.T
+	seen []int32
+	countingConsumer
+}
+
+func (this *exclusiveConsumer) Consume(lower, upper int64) {
+	for sequence := lower; sequence <= upper; sequence++ {
+		if atomic.AddInt32(&this.seen[sequence], 1) != 1 {
+			this.t.Errorf("sequence %d was consumed more than once", sequence)
+		}
+	}
+	this.countingConsumer.Consume(lower, upper)
+}
+
+func TestWireupWorkerPool(t *testing.T) {
+	const iterations = 1024 * 16
+
+	workers := &exclusiveConsumer{t: t, seen: make([]int32, iterations)}
+	downstream := &orderedConsumer{t: t}
+
+	myDisruptor := New(
+		WithCapacity(64),
+		WithWorkerPool("decode", 4, workers),
+		WithHandler("apply", downstream, After("decode")),
+	)
+
+	go func() {
+		for sequence := int64(0); sequence < iterations-1; {
+			sequence = myDisruptor.Reserve(1)
+			myDisruptor.Commit(sequence, sequence)
+		}
+		_ = myDisruptor.Close()
+	}()
+
+	myDisruptor.Read()
+
+	if workers.count != iterations || downstream.count != iterations {
+		t.Fatalf("expected %d events, got %d and %d", iterations, workers.count, downstream.count)
+	}
+}
@@ -0,0 +1,97 @@
+package disruptor
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// workerPool hands every sequence to exactly one of its workers, the workers claim
+// sequences from the shared work cursor so the consumer must be safe for concurrent use
+type workerPool struct {
+	state		int64
+	work		*Cursor   // the last sequence claimed by any of the workers
+	sequences	[]*Cursor // each worker has processed everything it claimed up to this sequence
+	written		*Cursor
+	upstream	Barrier
+	waiter		WaitStrategy
+	consumer	Consumer
+	metrics		*readerMetrics // shared by all of the workers
+}
+
+func newWorkerPool(sequences []*Cursor, written *Cursor, upstream Barrier, waiter WaitStrategy, consumer Consumer) *workerPool {
+	return &workerPool{
+		state:     stateRunning,
+		work:      NewCursor(),
+		sequences: sequences,
+		written:   written,
+		upstream:  upstream,
+		waiter:    waiter,
+		consumer:  consumer,
+		metrics:   &readerMetrics{},
+	}
+}
+
+func (this *workerPool) Read() {
+	var waiter sync.WaitGroup
+	waiter.Add(len(this.sequences))
+
+	for _, item := range this.sequences {
+		go func(current *Cursor) {
+			this.process(current)
+			waiter.Done()
+		}(item)
+	}
+	waiter.Wait()
+
+	if closer, ok := this.consumer.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+func (this *workerPool) process(current *Cursor) {
+	var gateCount, idleCount, sequence, upper int64
+
+	for atomic.LoadInt64(&this.state) != stateHalted {
+		// publish the claimed sequence minus one before claiming so that the gating
+		// cursor never runs ahead of a sequence this worker is still processing
+		for {
+			claimed := this.work.Load()
+			current.Store(claimed)
+			if this.work.CompareAndSwap(claimed, claimed+1) {
+				sequence = claimed + 1
+				break
+			}
+		}
+
+		for atomic.LoadInt64(&this.state) != stateHalted {
+			if upper = this.upstream.Load(); sequence <= upper {
+				this.consumer.Consume(sequence, sequence)
+				this.metrics.batch(sequence, sequence)
+				gateCount, idleCount = 0, 0
+				break
+			} else if upper = this.written.Load(); sequence <= upper {
+				gateCount++
+				idleCount = 0
+				this.metrics.gate()
+				this.waiter.Gate(gateCount)
+			} else if atomic.LoadInt64(&this.state) == stateRunning {
+				idleCount++
+				gateCount = 0
+				this.metrics.idle()
+				this.waiter.Idle(idleCount)
+			} else {
+				return
+			}
+		}
+	}
+}
+
+func (this *workerPool) Close() error {
+	atomic.CompareAndSwapInt64(&this.state, stateRunning, stateClosed)
+	return nil
+}
+
+func (this *workerPool) halt() {
+	atomic.StoreInt64(&this.state, stateHalted)
+}
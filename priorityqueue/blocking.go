@@ -0,0 +1,216 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrQueueClosed = errors.New("the queue has been closed")
+
+// entry is a value of the blocking priority queue, entries with the same priority leave the queue
+// in the order they were added
+type entry[T any] struct {
+	value    T
+	priority int64
+	sequence uint64
+}
+
+type entries[T any] []entry[T]
+
+func (this entries[T]) Len() int {
+	return len(this)
+}
+
+func (this entries[T]) Less(i, j int) bool {
+	if this[i].priority != this[j].priority {
+		return this[i].priority > this[j].priority
+	}
+	return this[i].sequence < this[j].sequence
+}
+
+func (this entries[T]) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+}
+
+func (this *entries[T]) Push(x any) {
+	*this = append(*this, x.(entry[T]))
+}
+
+func (this *entries[T]) Pop() any {
+	old := *this
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = entry[T]{} // avoid memory leak
+	*this = old[0 : n-1]
+	return item
+}
+
+// BlockingPriorityQueue is a bounded thread safe priority queue, the value with the highest priority
+// leaves the queue first. A queue with a capacity of zero only accepts values while a taker is waiting.
+type BlockingPriorityQueue[T any] struct {
+	mutex    sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	entries  entries[T]
+	capacity int
+	sequence uint64
+	takers   int
+	closed   bool
+}
+
+// Create a new empty BlockingPriorityQueue which holds at most capacity values
+func NewBlockingPriorityQueue[T any](capacity int) *BlockingPriorityQueue[T] {
+	q := new(BlockingPriorityQueue[T])
+	q.capacity = capacity
+	q.notEmpty = sync.NewCond(&q.mutex)
+	q.notFull = sync.NewCond(&q.mutex)
+	return q
+}
+
+// full is called with the mutex held, waiting takers make room for a value each
+func (this *BlockingPriorityQueue[T]) full() bool {
+	return len(this.entries) >= MaxInt(this.capacity, this.takers)
+}
+
+func (this *BlockingPriorityQueue[T]) push(value T, priority int64) {
+	this.sequence++
+	heap.Push(&this.entries, entry[T]{value: value, priority: priority, sequence: this.sequence})
+	this.notEmpty.Signal()
+}
+
+func (this *BlockingPriorityQueue[T]) pop() T {
+	value := heap.Pop(&this.entries).(entry[T]).value
+	this.notFull.Signal()
+	return value
+}
+
+// Offer adds the value unless the queue is full or closed, it returns whether the value was added
+func (this *BlockingPriorityQueue[T]) Offer(value T, priority int64) bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed || this.full() {
+		return false
+	}
+	this.push(value, priority)
+	return true
+}
+
+// Put adds the value, it blocks until there is room in the queue or the context is done
+func (this *BlockingPriorityQueue[T]) Put(ctx context.Context, value T, priority int64) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	watching := false
+	for !this.closed && this.full() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !watching {
+			watching = true
+			defer this.wakeOnDone(ctx, this.notFull)()
+		}
+		this.notFull.Wait()
+	}
+
+	if this.closed {
+		return ErrQueueClosed
+	}
+	this.push(value, priority)
+	return nil
+}
+
+// Take removes the value with the highest priority, it blocks until a value is available or the
+// context is done. A closed queue returns ErrQueueClosed once it is empty.
+func (this *BlockingPriorityQueue[T]) Take(ctx context.Context) (T, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	var empty T
+	if len(this.entries) == 0 {
+		this.takers++
+		this.notFull.Signal()
+		defer func() { this.takers-- }()
+	}
+
+	watching := false
+	for len(this.entries) == 0 {
+		if this.closed {
+			return empty, ErrQueueClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return empty, err
+		}
+		if !watching {
+			watching = true
+			defer this.wakeOnDone(ctx, this.notEmpty)()
+		}
+		this.notEmpty.Wait()
+	}
+
+	return this.pop(), nil
+}
+
+// wakeOnDone wakes the waiters of the condition once the context is done, the returned function
+// stops watching the context. It is called with the mutex held.
+func (this *BlockingPriorityQueue[T]) wakeOnDone(ctx context.Context, cond *sync.Cond) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			this.mutex.Lock()
+			cond.Broadcast()
+			this.mutex.Unlock()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// Poll removes the value with the highest priority without blocking
+func (this *BlockingPriorityQueue[T]) Poll() (T, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if len(this.entries) == 0 {
+		var empty T
+		return empty, false
+	}
+	return this.pop(), true
+}
+
+// Get the number of values in the queue
+func (this *BlockingPriorityQueue[T]) Len() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return len(this.entries)
+}
+
+// Drain removes all values in priority order
+func (this *BlockingPriorityQueue[T]) Drain() []T {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	values := make([]T, 0, len(this.entries))
+	for len(this.entries) > 0 {
+		values = append(values, this.pop())
+	}
+	this.notFull.Broadcast()
+	return values
+}
+
+// Close the queue, it accepts no more values and wakes every blocked Put
+// Take keeps returning the queued values until the queue is empty
+func (this *BlockingPriorityQueue[T]) Close() {
+	this.mutex.Lock()
+	this.closed = true
+	this.notEmpty.Broadcast()
+	this.notFull.Broadcast()
+	this.mutex.Unlock()
+}
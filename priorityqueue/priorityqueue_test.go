@@ -2,8 +2,10 @@ package priorityqueue
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestPriorityQueue(t *testing.T) {
@@ -40,3 +42,42 @@ func TestPriorityQueue(t *testing.T) {
 		fmt.Printf("%.2d:%s ", item.priority, item.value)
 	}
 }
+
+func TestBlockingPriorityQueue(t *testing.T) {
+	q := NewBlockingPriorityQueue[string](3)
+	q.Offer("batch", 0)
+	q.Offer("user", 10)
+	q.Offer("batch again", 0)
+	if q.Offer("overflow", 20) {
+		t.Fatal("expected the full queue to reject the value")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Put(ctx, "blocked", 0); err != context.DeadlineExceeded {
+		t.Fatalf("expected the put to time out, got %v", err)
+	}
+
+	for _, expected := range []string{"user", "batch", "batch again"} {
+		if value, err := q.Take(context.Background()); value != expected || err != nil {
+			t.Fatalf("expected %s, got %s %v", expected, value, err)
+		}
+	}
+
+	taken := make(chan string)
+	go func() {
+		value, _ := q.Take(context.Background())
+		taken <- value
+	}()
+	if err := q.Put(context.Background(), "handed", 0); err != nil {
+		t.Fatal(err)
+	}
+	if value := <-taken; value != "handed" {
+		t.Fatalf("expected the blocked take to get the value, got %s", value)
+	}
+
+	q.Close()
+	if _, err := q.Take(context.Background()); err != ErrQueueClosed {
+		t.Fatalf("expected the closed queue to be empty, got %v", err)
+	}
+}
@@ -1,31 +1,157 @@
 package threadpool
 
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrFutureCancelled = fmt.Errorf("the task has been cancelled")
+	ErrFutureTimeout   = fmt.Errorf("timed out waiting for the task")
+)
+
 // Callable the tasks which returns the output after exit should implement this interface
-type Callable interface {
-	Call() interface{}
+type Callable[T any] interface {
+	Call() (T, error)
 }
 
+// CallableFunc adapts a function to a Callable
+type CallableFunc[T any] func() (T, error)
+
+func (c CallableFunc[T]) Call() (T, error) {
+	return c()
+}
 
 // Future is the handle returned after submitting a callable task to the tread pool
-type Future struct {
-	response	chan interface{}
-	done 		bool
+// It is completed exactly once, either by the task or by Cancel, and can be read any number of times
+type Future[T any] struct {
+	done		chan struct{}
+	once		sync.Once
+	result		T
+	err		error
+	cancelled	int32
+	// callbacks run once the future is completed, see onComplete
+	mutex		sync.Mutex
+	callbacks	[]func()
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
 }
 
 // callableTask is internally used to wrap the callable and future together
-// So that the worker can send the response back through channel provided in Future object
-type callableTask struct {
-	Task		Callable
-	Handle		*Future
+// So that the worker can complete the future with the outcome of the task
+type callableTask[T any] struct {
+	Task		Callable[T]
+	Handle		*Future[T]
+}
+
+// Run lets callable tasks travel through the pool like any other Runnable
+func (c callableTask[T]) Run() {
+	// Skip the tasks which have been cancelled while they were queued
+	if c.Handle.IsDone() {
+		return
+	}
+	// The future fails with the panic before the worker reports it
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err := newPanicError(recovered)
+			var empty T
+			c.Handle.complete(empty, err)
+			panic(err)
+		}
+	}()
+	result, err := c.Task.Call()
+	c.Handle.complete(result, err)
+}
+
+// complete stores the outcome of the task, only the first call has an effect
+func (f *Future[T]) complete(result T, err error) bool {
+	return f.settle(result, err, false)
+}
+
+// settle completes the future, a cancellation is flagged before the waiters are woken up so that
+// they see IsCancelled together with ErrFutureCancelled
+func (f *Future[T]) settle(result T, err error, cancelled bool) bool {
+	completed := false
+	f.once.Do(func() {
+		f.result, f.err = result, err
+		if cancelled {
+			atomic.StoreInt32(&f.cancelled, 1)
+		}
+		f.mutex.Lock()
+		close(f.done)
+		callbacks := f.callbacks
+		f.callbacks = nil
+		f.mutex.Unlock()
+
+		for _, callback := range callbacks {
+			callback()
+		}
+		completed = true
+	})
+	return completed
+}
+
+// onComplete runs the callback once the future is completed, right away if it already is
+func (f *Future[T]) onComplete(callback func()) {
+	f.mutex.Lock()
+	if !f.IsDone() {
+		f.callbacks = append(f.callbacks, callback)
+		f.mutex.Unlock()
+		return
+	}
+	f.mutex.Unlock()
+	callback()
 }
 
 // Get returns the response of the Callable task when done
-// Is is the blocking call it waits for the execution to complete
-func (f *Future) Get() interface{} {
-	return <- f.response
+// Is is the blocking call it waits for the execution to complete or for the context to be done
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		var empty T
+		return empty, ctx.Err()
+	}
+}
+
+// GetTimeout waits at most the timeout for the response, it returns ErrFutureTimeout when the task is not done by then
+func (f *Future[T]) GetTimeout(timeout time.Duration) (T, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-timer.C:
+		var empty T
+		return empty, ErrFutureTimeout
+	}
+}
+
+// Cancel completes the future with ErrFutureCancelled unless it is already done
+// A queued task is skipped, a running task keeps running but its response is discarded
+func (f *Future[T]) Cancel() bool {
+	var empty T
+	return f.settle(empty, ErrFutureCancelled, true)
+}
+
+// IsCancelled returns true if the future was cancelled before the task completed it
+func (f *Future[T]) IsCancelled() bool {
+	return atomic.LoadInt32(&f.cancelled) == 1
 }
 
 // IsDone returns true if the execution is already done
-func (f *Future) IsDone() bool {
-	return f.done
-}
\ No newline at end of file
+func (f *Future[T]) IsDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,57 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFutureCarriesResultAndError(t *testing.T) {
+	pool := NewThreadPool(2, 10)
+
+	errFailed := errors.New("failed")
+	succeeded, _ := Submit[int](pool, CallableFunc[int](func() (int, error) { return 42, nil }))
+	failed, _ := Submit[int](pool, CallableFunc[int](func() (int, error) { return 0, errFailed }))
+
+	for i := 0; i < 2; i++ {
+		if result, err := succeeded.Get(context.Background()); result != 42 || err != nil {
+			t.Fatalf("expected 42, got %d and %v", result, err)
+		}
+	}
+	if _, err := failed.GetTimeout(time.Second); err != errFailed {
+		t.Fatalf("expected the error of the task, got %v", err)
+	}
+}
+
+func TestFutureTimeoutAndCancel(t *testing.T) {
+	pool := NewThreadPool(1, 10)
+
+	release := make(chan struct{})
+	blocked, _ := Submit[int](pool, CallableFunc[int](func() (int, error) {
+		<-release
+		return 1, nil
+	}))
+
+	if _, err := blocked.GetTimeout(10 * time.Millisecond); err != ErrFutureTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if !blocked.Cancel() || !blocked.IsCancelled() || blocked.Cancel() {
+		t.Fatal("expected only the first cancel to succeed")
+	}
+	if _, err := blocked.Get(context.Background()); err != ErrFutureCancelled {
+		t.Fatalf("expected the future to be cancelled, got %v", err)
+	}
+	close(release)
+}
+
+func TestFutureIsCancelledBeforeWaitersWake(t *testing.T) {
+	future := newFuture[int]()
+	seen := make(chan bool, 1)
+	future.onComplete(func() { seen <- future.IsCancelled() })
+
+	future.Cancel()
+	if !<-seen {
+		t.Fatal("expected the future to be flagged as cancelled when it completes")
+	}
+}
@@ -0,0 +1,162 @@
+package threadpool
+
+import "sync/atomic"
+
+// The functions below chain futures without blocking a worker on Get. Every continuation runs on
+// the given pool once the futures it depends on are completed, or on the goroutine completing them
+// when the pool is nil. A continuation which the pool rejects completes its future with the error.
+
+// continueOn runs the step on the pool or inline, the future fails when the pool rejects the step
+// or when the step panics
+func continueOn[T any](pool *ThreadPool, future *Future[T], step func()) {
+	guarded := func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				var empty T
+				future.complete(empty, newPanicError(recovered))
+			}
+		}()
+		step()
+	}
+
+	if pool == nil {
+		guarded()
+		return
+	}
+	if err := pool.Execute(RunnableFunc(guarded)); err != nil {
+		var empty T
+		future.complete(empty, err)
+	}
+}
+
+// ThenApply returns the future of fn applied to the response, an error skips fn and is passed on
+func ThenApply[T, U any](f *Future[T], pool *ThreadPool, fn func(T) (U, error)) *Future[U] {
+	next := newFuture[U]()
+	f.onComplete(func() {
+		if f.err != nil {
+			var empty U
+			next.complete(empty, f.err)
+			return
+		}
+		continueOn(pool, next, func() {
+			next.complete(fn(f.result))
+		})
+	})
+	return next
+}
+
+// ThenCompose returns the future of the future which fn starts with the response
+func ThenCompose[T, U any](f *Future[T], pool *ThreadPool, fn func(T) *Future[U]) *Future[U] {
+	next := newFuture[U]()
+	f.onComplete(func() {
+		if f.err != nil {
+			var empty U
+			next.complete(empty, f.err)
+			return
+		}
+		continueOn(pool, next, func() {
+			composed := fn(f.result)
+			composed.onComplete(func() {
+				next.complete(composed.result, composed.err)
+			})
+		})
+	})
+	return next
+}
+
+// ThenCombine returns the future of fn applied to the responses of both futures
+func ThenCombine[T, U, V any](f *Future[T], g *Future[U], pool *ThreadPool, fn func(T, U) (V, error)) *Future[V] {
+	next := newFuture[V]()
+	var pending int32 = 2
+
+	both := func() {
+		if atomic.AddInt32(&pending, -1) != 0 {
+			return
+		}
+		if err := firstError(f.err, g.err); err != nil {
+			var empty V
+			next.complete(empty, err)
+			return
+		}
+		continueOn(pool, next, func() {
+			next.complete(fn(f.result, g.result))
+		})
+	}
+	f.onComplete(both)
+	g.onComplete(both)
+	return next
+}
+
+// Exceptionally returns a future which replaces an error with the outcome of fn, a response is passed on
+func Exceptionally[T any](f *Future[T], pool *ThreadPool, fn func(error) (T, error)) *Future[T] {
+	next := newFuture[T]()
+	f.onComplete(func() {
+		if f.err == nil {
+			next.complete(f.result, nil)
+			return
+		}
+		continueOn(pool, next, func() {
+			next.complete(fn(f.err))
+		})
+	})
+	return next
+}
+
+// Handle returns the future of fn applied to either the response or the error
+func Handle[T, U any](f *Future[T], pool *ThreadPool, fn func(T, error) (U, error)) *Future[U] {
+	next := newFuture[U]()
+	f.onComplete(func() {
+		continueOn(pool, next, func() {
+			next.complete(fn(f.result, f.err))
+		})
+	})
+	return next
+}
+
+// AllOf returns the future of all responses in order, it fails as soon as one of the futures fails
+func AllOf[T any](futures ...*Future[T]) *Future[[]T] {
+	next := newFuture[[]T]()
+	results := make([]T, len(futures))
+	pending := int32(len(futures))
+
+	if pending == 0 {
+		next.complete(results, nil)
+		return next
+	}
+
+	for i, item := range futures {
+		i, item := i, item
+		item.onComplete(func() {
+			if item.err != nil {
+				next.complete(nil, item.err)
+				return
+			}
+			results[i] = item.result
+			if atomic.AddInt32(&pending, -1) == 0 {
+				next.complete(results, nil)
+			}
+		})
+	}
+	return next
+}
+
+// AnyOf returns a future which is completed like the first of the futures which completes
+func AnyOf[T any](futures ...*Future[T]) *Future[T] {
+	next := newFuture[T]()
+	for _, item := range futures {
+		item := item
+		item.onComplete(func() {
+			next.complete(item.result, item.err)
+		})
+	}
+	return next
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
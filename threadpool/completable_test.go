@@ -0,0 +1,75 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func value[T any](result T) *Future[T] {
+	future := newFuture[T]()
+	future.complete(result, nil)
+	return future
+}
+
+func TestFutureComposition(t *testing.T) {
+	pool := NewThreadPool(4, 100)
+
+	source, _ := Submit[int](pool, CallableFunc[int](func() (int, error) {
+		time.Sleep(time.Millisecond)
+		return 20, nil
+	}))
+
+	doubled := ThenApply(source, pool, func(result int) (int, error) { return result * 2, nil })
+	composed := ThenCompose(doubled, pool, func(result int) *Future[int] {
+		future, _ := Submit[int](pool, CallableFunc[int](func() (int, error) { return result + 2, nil }))
+		return future
+	})
+	combined := ThenCombine(composed, value("answer"), pool, func(result int, name string) (string, error) {
+		return name + "=" + strconv.Itoa(result), nil
+	})
+
+	if result, err := combined.GetTimeout(time.Second); result != "answer=42" || err != nil {
+		t.Fatalf("expected answer=42, got %q and %v", result, err)
+	}
+
+	all, err := AllOf(value(1), doubled, composed).Get(context.Background())
+	if err != nil || len(all) != 3 || all[0] != 1 || all[1] != 40 || all[2] != 42 {
+		t.Fatalf("expected all responses in order, got %v and %v", all, err)
+	}
+	if first, _ := AnyOf(newFuture[int](), value(7)).Get(context.Background()); first != 7 {
+		t.Fatalf("expected the completed future to win, got %d", first)
+	}
+}
+
+func TestFutureCompositionErrors(t *testing.T) {
+	pool := NewThreadPool(2, 100)
+	errFailed := errors.New("failed")
+
+	failed := newFuture[int]()
+	failed.complete(0, errFailed)
+
+	applied := ThenApply(failed, pool, func(result int) (int, error) {
+		t.Error("expected the error to skip the function")
+		return result, nil
+	})
+	if _, err := applied.GetTimeout(time.Second); err != errFailed {
+		t.Fatalf("expected the error to be passed on, got %v", err)
+	}
+
+	recovered := Exceptionally(applied, pool, func(err error) (int, error) { return -1, nil })
+	if result, err := recovered.GetTimeout(time.Second); result != -1 || err != nil {
+		t.Fatalf("expected the error to be replaced, got %d and %v", result, err)
+	}
+
+	handled := Handle(failed, nil, func(result int, err error) (string, error) { return err.Error(), nil })
+	if result, _ := handled.GetTimeout(time.Second); result != "failed" {
+		t.Fatalf("expected the handler to see the error, got %q", result)
+	}
+
+	if _, err := AllOf(value(1), failed).GetTimeout(time.Second); err != errFailed {
+		t.Fatalf("expected AllOf to fail, got %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+package threadpool
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrCronExpression = fmt.Errorf("invalid cron expression")
+
+// cronSearchYears bounds the search for expressions which never match, e.g. the 30th of February
+const cronSearchYears = 5
+
+// MisfirePolicy decides what happens to a cron run which is late, e.g. because the pool was busy
+// or the process was paused
+type MisfirePolicy int
+
+const (
+	// MisfireRunOnce runs a late run right away, the other fire times which have passed are skipped
+	MisfireRunOnce MisfirePolicy = iota
+	// MisfireSkip skips a run which is late by at least one fire time and waits for the next one
+	MisfireSkip
+)
+
+// CronSchedule is a parsed cron expression
+// It takes 5 fields (minute, hour, day of month, month, day of week) or 6 fields with the seconds
+// first. A field is *, a value, a range a-b, a list a,b and any of them with a step /n. Months and
+// weekdays may be given by their first three letters, Sunday is 0 or 7. When both the day of month
+// and the day of week are restricted, a day matching either of them fires.
+// The macros @yearly, @annually, @monthly, @weekly, @daily, @midnight and @hourly are supported.
+//
+// The fields are matched against the wall clock of the location. A wall clock time which
+// occurs twice fires only once. A wall clock time in the gap of a daylight saving change is shifted
+// forward by the length of the gap, e.g. 2:30 becomes 3:30 when the clocks go from 2:00 to 3:00.
+type CronSchedule struct {
+	second     uint64
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+	// a restricted day of month and day of week are combined with or
+	restrictedDays bool
+	location       *time.Location
+	misfire        MisfirePolicy
+}
+
+// CronOption configures a cron schedule
+type CronOption func(*CronSchedule)
+
+// WithLocation sets the time zone of the cron expression, it defaults to time.Local
+func WithLocation(location *time.Location) CronOption {
+	return func(c *CronSchedule) {
+		c.location = location
+	}
+}
+
+// WithMisfirePolicy sets how late runs are handled, it defaults to MisfireRunOnce
+func WithMisfirePolicy(policy MisfirePolicy) CronOption {
+	return func(c *CronSchedule) {
+		c.misfire = policy
+	}
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField     = cronField{min: 0, max: 59}
+	minuteField     = cronField{min: 0, max: 59}
+	hourField       = cronField{min: 0, max: 23}
+	dayOfMonthField = cronField{min: 1, max: 31}
+	monthField      = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dayOfWeekField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseCron parses a 5 or 6 field cron expression or one of the macros
+func ParseCron(expression string, options ...CronOption) (*CronSchedule, error) {
+	c := &CronSchedule{location: time.Local, misfire: MisfireRunOnce}
+	for _, option := range options {
+		option(c)
+	}
+	if c.location == nil {
+		c.location = time.UTC
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) == 1 {
+		macro, ok := cronMacros[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown macro %q", ErrCronExpression, fields[0])
+		}
+		fields = strings.Fields(macro)
+	}
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("%w: expected 5 or 6 fields, got %d", ErrCronExpression, len(fields))
+	}
+
+	var err error
+	var dayOfMonthAll, dayOfWeekAll bool
+	if c.second, _, err = secondField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if c.minute, _, err = minuteField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if c.hour, _, err = hourField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if c.dayOfMonth, dayOfMonthAll, err = dayOfMonthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if c.month, _, err = monthField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if c.dayOfWeek, dayOfWeekAll, err = dayOfWeekField.parse(fields[5]); err != nil {
+		return nil, err
+	}
+
+	// Sunday may be written as 7
+	if c.dayOfWeek&(1<<7) != 0 {
+		c.dayOfWeek = c.dayOfWeek&^(1<<7) | 1
+	}
+	c.restrictedDays = !dayOfMonthAll && !dayOfWeekAll
+	return c, nil
+}
+
+// parse returns the bits of the values matched by the field and whether it matches every value
+func (f cronField) parse(field string) (uint64, bool, error) {
+	var bits uint64
+	all := false
+
+	for _, part := range strings.Split(field, ",") {
+		expression, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			value, err := strconv.Atoi(part[i+1:])
+			if err != nil || value <= 0 {
+				return 0, false, fmt.Errorf("%w: invalid step in %q", ErrCronExpression, part)
+			}
+			expression, step = part[:i], value
+		}
+
+		var lower, upper int
+		switch {
+		case expression == "*" || expression == "?":
+			lower, upper = f.min, f.max
+			all = all || step == 1
+		case strings.Contains(expression, "-"):
+			bounds := strings.SplitN(expression, "-", 2)
+			var err error
+			if lower, err = f.value(bounds[0]); err != nil {
+				return 0, false, err
+			}
+			if upper, err = f.value(bounds[1]); err != nil {
+				return 0, false, err
+			}
+			if lower > upper {
+				return 0, false, fmt.Errorf("%w: empty range %q", ErrCronExpression, part)
+			}
+		default:
+			var err error
+			if lower, err = f.value(expression); err != nil {
+				return 0, false, err
+			}
+			upper = lower
+			// a/n steps from a up to the end of the field
+			if step > 1 || strings.Contains(part, "/") {
+				upper = f.max
+			}
+		}
+
+		for value := lower; value <= upper; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, all, nil
+}
+
+func (f cronField) value(text string) (int, error) {
+	if value, ok := f.names[strings.ToLower(text)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("%w: %q is not between %d and %d", ErrCronExpression, text, f.min, f.max)
+	}
+	return value, nil
+}
+
+func (c *CronSchedule) matchesDay(wall time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(wall.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(wall.Weekday())) != 0
+	if c.restrictedDays {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+// Next returns the first fire time after the moment, the zero time if there is none
+func (c *CronSchedule) Next(moment time.Time) time.Time {
+	wall := c.wallClock(moment)
+	for {
+		if wall = c.nextWall(wall); wall.IsZero() {
+			return time.Time{}
+		}
+		// a wall clock time which occurs twice resolves to its first instant, which may be before the moment
+		fire := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, c.location)
+		if !c.wallClock(fire).Equal(wall) {
+			// the wall clock time falls into a daylight saving gap, it is read with the offset from before the gap
+			_, offset := fire.Add(-24 * time.Hour).Zone()
+			fire = wall.Add(-time.Duration(offset) * time.Second).In(c.location)
+		}
+		if fire.After(moment) {
+			return fire
+		}
+	}
+}
+
+// wallClock returns the wall clock of the moment in the location expressed in UTC
+func (c *CronSchedule) wallClock(moment time.Time) time.Time {
+	local := moment.In(c.location)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+}
+
+// NextN lists the next n fire times after the moment
+func (c *CronSchedule) NextN(moment time.Time, n int) []time.Time {
+	var fires []time.Time
+	for len(fires) < n {
+		if moment = c.Next(moment); moment.IsZero() {
+			break
+		}
+		fires = append(fires, moment)
+	}
+	return fires
+}
+
+// nextWall returns the first matching wall clock time after the given one, both are expressed in UTC
+// so that the search does not depend on daylight saving changes
+func (c *CronSchedule) nextWall(wall time.Time) time.Time {
+	wall = wall.Add(time.Second)
+	limit := wall.Year() + cronSearchYears
+
+	// Once a field has been advanced the smaller ones start from their lowest value
+	truncated := false
+
+wrap:
+	if wall.Year() > limit {
+		return time.Time{}
+	}
+
+	for c.month&(1<<uint(wall.Month())) == 0 {
+		if !truncated {
+			truncated = true
+			wall = time.Date(wall.Year(), wall.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		wall = wall.AddDate(0, 1, 0)
+		if wall.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !c.matchesDay(wall) {
+		if !truncated {
+			truncated = true
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		wall = wall.AddDate(0, 0, 1)
+		if wall.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for c.hour&(1<<uint(wall.Hour())) == 0 {
+		if !truncated {
+			truncated = true
+			wall = wall.Truncate(time.Hour)
+		}
+		wall = wall.Add(time.Hour)
+		if wall.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for c.minute&(1<<uint(wall.Minute())) == 0 {
+		if !truncated {
+			truncated = true
+			wall = wall.Truncate(time.Minute)
+		}
+		wall = wall.Add(time.Minute)
+		if wall.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for c.second&(1<<uint(wall.Second())) == 0 {
+		if !truncated {
+			truncated = true
+			wall = wall.Truncate(time.Second)
+		}
+		wall = wall.Add(time.Second)
+		if wall.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return wall
+}
+
+// cronTiming keeps track of the fire time of the pending run of a cron task
+type cronTiming struct {
+	schedule *CronSchedule
+	fire     time.Time
+}
+
+// late reports whether the run is late by at least one fire time
+func (c *cronTiming) late(now time.Time) bool {
+	next := c.schedule.Next(c.fire)
+	return !next.IsZero() && !next.After(now)
+}
+
+// next moves on to the first fire time after now and returns the delay until then
+func (c *cronTiming) next(now time.Time) (time.Duration, bool) {
+	if c.fire = c.schedule.Next(now); c.fire.IsZero() {
+		return 0, false
+	}
+	return c.fire.Sub(now), true
+}
+
+// ScheduleCron runs the task at every fire time of the cron expression, runs never overlap
+// The future completes without an error once the expression has no fire times left
+func (stf *ScheduledThreadPool) ScheduleCron(expression string, task Runnable, options ...CronOption) (*ScheduledFuture[any], error) {
+	schedule, err := ParseCron(expression, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	timing := &cronTiming{schedule: schedule}
+	delay, ok := timing.next(time.Now())
+	if !ok {
+		return nil, fmt.Errorf("%w: %q never fires", ErrCronExpression, expression)
+	}
+
+	periodic := &periodicTask{pool: stf, task: task, cron: timing, future: newFuture[any]()}
+	entry, err := stf.schedule(periodic, delay)
+	if err != nil {
+		return nil, err
+	}
+	return &ScheduledFuture[any]{Future: periodic.future, pool: stf, entry: entry}, nil
+}
@@ -0,0 +1,105 @@
+package threadpool
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expression := range []string{"* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@often"} {
+		if _, err := ParseCron(expression); !errors.Is(err, ErrCronExpression) {
+			t.Errorf("expected %q to be rejected, got %v", expression, err)
+		}
+	}
+}
+
+func TestCronNextN(t *testing.T) {
+	start := time.Date(2026, time.March, 2, 10, 7, 0, 0, time.UTC) // a Monday
+
+	cases := []struct {
+		expression string
+		expected   []time.Time
+	}{
+		{"*/15 * * * *", []time.Time{
+			time.Date(2026, time.March, 2, 10, 15, 0, 0, time.UTC),
+			time.Date(2026, time.March, 2, 10, 30, 0, 0, time.UTC),
+			time.Date(2026, time.March, 2, 10, 45, 0, 0, time.UTC),
+		}},
+		{"30 0 9 * * sat,sun", []time.Time{
+			time.Date(2026, time.March, 7, 9, 0, 30, 0, time.UTC),
+			time.Date(2026, time.March, 8, 9, 0, 30, 0, time.UTC),
+		}},
+		// a restricted day of month and day of week are combined with or
+		{"0 12 13 * FRI", []time.Time{
+			time.Date(2026, time.March, 6, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, time.March, 13, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, time.March, 20, 12, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 29 2 *", []time.Time{time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)}},
+	}
+
+	for _, item := range cases {
+		schedule, err := ParseCron(item.expression, WithLocation(time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fires := schedule.NextN(start, len(item.expected))
+		for i := range item.expected {
+			if i >= len(fires) || !fires[i].Equal(item.expected[i]) {
+				t.Fatalf("%s: expected %v, got %v", item.expression, item.expected, fires)
+			}
+		}
+	}
+
+	never, _ := ParseCron("0 0 30 2 *")
+	if !never.Next(start).IsZero() {
+		t.Fatal("expected the 30th of February never to fire")
+	}
+}
+
+func TestCronDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the clocks go from 2:00 to 3:00 on the 8th of March 2026
+	skipped, _ := ParseCron("30 2 * * *", WithLocation(newYork))
+	fire := skipped.Next(time.Date(2026, time.March, 8, 0, 0, 0, 0, newYork))
+	if expected := time.Date(2026, time.March, 8, 7, 30, 0, 0, time.UTC); !fire.Equal(expected) {
+		t.Fatalf("expected the skipped time to fire after the gap at %v, got %v", expected, fire)
+	}
+
+	// the clocks go from 2:00 back to 1:00 on the 1st of November 2026
+	repeated, _ := ParseCron("*/30 1 * * *", WithLocation(newYork))
+	fires := repeated.NextN(time.Date(2026, time.November, 1, 0, 0, 0, 0, newYork), 3)
+	if len(fires) != 3 || fires[2].Day() != 2 {
+		t.Fatalf("expected the repeated hour to fire once, got %v", fires)
+	}
+}
+
+func TestScheduleCron(t *testing.T) {
+	pool := NewScheduledThreadPool(1)
+	defer pool.ShutdownNow()
+
+	var runs int32
+	future, err := pool.ScheduleCron("* * * * * *", RunnableFunc(func() { atomic.AddInt32(&runs, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay := future.GetDelay(); delay > time.Second+time.Millisecond {
+		t.Fatalf("expected the next run within a second, got %v", delay)
+	}
+
+	for deadline := time.Now().Add(3 * time.Second); atomic.LoadInt32(&runs) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the cron task to run")
+		}
+	}
+	if !future.Cancel() || pool.Pending() != 0 {
+		t.Fatal("expected the cancelled cron task to leave the schedule")
+	}
+}
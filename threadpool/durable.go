@@ -0,0 +1,680 @@
+package threadpool
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownJobType    = fmt.Errorf("the job type has not been registered")
+	ErrUnknownJob        = fmt.Errorf("there is no job with this id")
+	ErrJobStoreClosed    = fmt.Errorf("the job store has been closed")
+	ErrVisibilityTimeout = fmt.Errorf("the job did not finish within its visibility timeout")
+)
+
+const (
+	jobRecordHeaderSize      = 8 // body length and checksum
+	defaultPollInterval      = 100 * time.Millisecond
+	defaultVisibilityTimeout = 30 * time.Second
+	// the log is compacted on open once it holds this many records more than there are jobs
+	compactionThreshold = 1024
+)
+
+// The operations of the job log, replaying them in order rebuilds the index
+const (
+	opEnqueue = "enqueue"
+	opLease   = "lease"
+	opRetry   = "retry"
+	opRelease = "release"
+	opAck     = "ack"
+	opDead    = "dead"
+	opRevive  = "revive"
+)
+
+// JobHandler runs a job of a registered type, an error schedules a retry
+// The context is cancelled once the visibility timeout of the job has passed
+type JobHandler func(ctx context.Context, payload []byte) error
+
+// RetryPolicy decides how often a failed job is retried and how long it waits in between
+// MaxAttempts includes the first run. The n-th retry waits InitialBackoff * Multiplier^(n-1),
+// at most MaxBackoff, varied by +-Jitter of it.
+type RetryPolicy struct {
+	MaxAttempts	int
+	InitialBackoff	time.Duration
+	MaxBackoff	time.Duration
+	Multiplier	float64
+	// fraction of the backoff between 0 and 1
+	Jitter		float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     5 * time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// Backoff returns the wait before the retry after the given number of attempts
+func (r RetryPolicy) Backoff(attempts int) time.Duration {
+	multiplier := math.Max(r.Multiplier, 1)
+	backoff := float64(r.InitialBackoff) * math.Pow(multiplier, float64(attempts-1))
+	if r.MaxBackoff > 0 && backoff > float64(r.MaxBackoff) {
+		backoff = float64(r.MaxBackoff)
+	}
+	if r.Jitter > 0 {
+		backoff += backoff * r.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// Job is a persisted unit of work
+type Job struct {
+	ID		uint64
+	Type		string
+	Payload		[]byte
+	// how many times the job has been started
+	Attempts	int
+	LastError	string
+	// when the job becomes visible to the poller again
+	VisibleAt	time.Time
+}
+
+// jobRecord is an entry of the append only log
+type jobRecord struct {
+	Op      string    `json:"op"`
+	ID      uint64    `json:"id"`
+	Type    string    `json:"type,omitempty"`
+	Payload []byte    `json:"payload,omitempty"`
+	At      time.Time `json:"at,omitempty"`
+	Attempt int       `json:"attempt,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type jobEntry struct {
+	Job
+	leased	bool
+	dead	bool
+}
+
+type jobType struct {
+	handler			JobHandler
+	retryPolicy		RetryPolicy
+	visibilityTimeout	time.Duration
+}
+
+// JobOption configures a registered job type
+type JobOption func(*jobType)
+
+// WithRetryPolicy sets how the jobs of the type are retried, see DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) JobOption {
+	return func(j *jobType) {
+		j.retryPolicy = policy
+	}
+}
+
+// WithVisibilityTimeout sets how long a started job stays invisible to the poller, a job which has
+// not finished by then is started again
+func WithVisibilityTimeout(timeout time.Duration) JobOption {
+	return func(j *jobType) {
+		j.visibilityTimeout = timeout
+	}
+}
+
+// DurableOption configures a durable queue
+type DurableOption func(*DurableQueue)
+
+// WithPollInterval sets how often the queue looks for jobs which have become visible
+func WithPollInterval(interval time.Duration) DurableOption {
+	return func(d *DurableQueue) {
+		d.pollInterval = interval
+	}
+}
+
+// DurableQueue keeps jobs in an append only log file and runs them on the pool, a job is only removed
+// once its handler has succeeded, so every job runs at least once even across restarts. Failed jobs
+// are retried with exponential backoff, a job which runs out of attempts moves to the dead letters.
+// The log is replayed into an in memory index on open, a record torn by a crash is truncated.
+type DurableQueue struct {
+	pool		*ThreadPool
+	path		string
+	pollInterval	time.Duration
+
+	mutex		sync.Mutex
+	file		*os.File
+	buffer		*bufio.Writer
+	records		int
+	jobs		map[uint64]*jobEntry
+	nextID		uint64
+	types		map[string]*jobType
+	closing		bool
+	closed		bool
+
+	wakeup		chan struct{}
+	closeHandle	chan struct{}
+	pollerDone	chan struct{}
+	started		sync.Once
+	running		sync.WaitGroup
+}
+
+// OpenDurableQueue opens or creates the job log at the path, jobs run on the pool once Start is called
+func OpenDurableQueue(pool *ThreadPool, path string, options ...DurableOption) (*DurableQueue, error) {
+	d := &DurableQueue{
+		pool:         pool,
+		path:         path,
+		pollInterval: defaultPollInterval,
+		jobs:         make(map[uint64]*jobEntry),
+		nextID:       1,
+		types:        make(map[string]*jobType),
+		wakeup:       make(chan struct{}, 1),
+		closeHandle:  make(chan struct{}),
+		pollerDone:   make(chan struct{}),
+	}
+	for _, option := range options {
+		option(d)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	size, err := d.replay()
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Truncate(path, size); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if d.records > len(d.jobs)+compactionThreshold {
+		if err = d.compact(); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	if err = d.openLog(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DurableQueue) openLog() error {
+	file, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	d.file = file
+	d.buffer = bufio.NewWriter(file)
+	return nil
+}
+
+// replay rebuilds the index from the log and returns the size of its intact records
+func (d *DurableQueue) replay() (int64, error) {
+	file, err := os.Open(d.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var reader = bufio.NewReader(file)
+	var header [jobRecordHeaderSize]byte
+	var size int64
+
+	for {
+		if _, err := io.ReadFull(reader, header[:]); err == io.EOF {
+			return size, nil
+		} else if err == io.ErrUnexpectedEOF {
+			return size, nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		body := make([]byte, binary.LittleEndian.Uint32(header[0:4]))
+		if _, err := io.ReadFull(reader, body); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return size, nil
+		} else if err != nil {
+			return 0, err
+		}
+		var record jobRecord
+		if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[4:8]) || json.Unmarshal(body, &record) != nil {
+			// only a crash while appending leaves a broken record, everything after it is dropped
+			return size, nil
+		}
+
+		d.apply(record)
+		size += int64(jobRecordHeaderSize + len(body))
+	}
+}
+
+// apply updates the index with a record, it is called with the mutex held or while opening
+func (d *DurableQueue) apply(record jobRecord) {
+	d.records++
+	if record.ID >= d.nextID {
+		d.nextID = record.ID + 1
+	}
+
+	if record.Op == opEnqueue {
+		d.jobs[record.ID] = &jobEntry{Job: Job{
+			ID:        record.ID,
+			Type:      record.Type,
+			Payload:   record.Payload,
+			Attempts:  record.Attempt,
+			LastError: record.Error,
+			VisibleAt: record.At,
+		}}
+		return
+	}
+
+	entry, ok := d.jobs[record.ID]
+	if !ok {
+		return
+	}
+	switch record.Op {
+	case opLease:
+		entry.leased, entry.Attempts, entry.VisibleAt = true, record.Attempt, record.At
+	case opRetry:
+		entry.leased, entry.VisibleAt, entry.LastError = false, record.At, record.Error
+	case opRelease:
+		entry.leased, entry.Attempts, entry.VisibleAt = false, record.Attempt, record.At
+	case opAck:
+		delete(d.jobs, record.ID)
+	case opDead:
+		entry.leased, entry.dead, entry.LastError = false, true, record.Error
+	case opRevive:
+		entry.dead, entry.Attempts, entry.VisibleAt = false, 0, record.At
+	}
+}
+
+// append writes the records, syncs them to disk and applies them to the index
+// It is called with the mutex held
+func (d *DurableQueue) append(records ...jobRecord) error {
+	if d.closed {
+		return ErrJobStoreClosed
+	}
+
+	var header [jobRecordHeaderSize]byte
+	for _, record := range records {
+		body, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(header[0:4], uint32(len(body)))
+		binary.LittleEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(body))
+		if _, err = d.buffer.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err = d.buffer.Write(body); err != nil {
+			return err
+		}
+	}
+	if err := d.buffer.Flush(); err != nil {
+		return err
+	}
+	if err := d.file.Sync(); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		d.apply(record)
+	}
+	return nil
+}
+
+// compact rewrites the log with one record per job and replaces the old one
+func (d *DurableQueue) compact() error {
+	var ids []uint64
+	for id := range d.jobs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var records []jobRecord
+	for _, id := range ids {
+		entry := d.jobs[id]
+		records = append(records, jobRecord{Op: opEnqueue, ID: id, Type: entry.Type, Payload: entry.Payload,
+			At: entry.VisibleAt, Attempt: entry.Attempts, Error: entry.LastError})
+		if entry.leased {
+			records = append(records, jobRecord{Op: opLease, ID: id, Attempt: entry.Attempts, At: entry.VisibleAt})
+		}
+		if entry.dead {
+			records = append(records, jobRecord{Op: opDead, ID: id, Error: entry.LastError})
+		}
+	}
+
+	if d.file != nil {
+		if err := d.buffer.Flush(); err != nil {
+			return err
+		}
+		if err := d.file.Close(); err != nil {
+			return err
+		}
+		d.file, d.buffer = nil, nil
+	}
+
+	temporary := d.path + ".compact"
+	file, err := os.OpenFile(temporary, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	d.file, d.buffer = file, bufio.NewWriter(file)
+
+	// the records are applied again on top of the current index, the result is the same
+	d.records = 0
+	nextID := d.nextID
+	if err = d.append(records...); err != nil {
+		_ = file.Close()
+		return err
+	}
+	d.nextID = nextID
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(temporary, d.path); err != nil {
+		return err
+	}
+	return d.openLog()
+}
+
+// Compact rewrites the log with one record per job, the records of finished jobs are dropped
+func (d *DurableQueue) Compact() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		return ErrJobStoreClosed
+	}
+	return d.compact()
+}
+
+// Register sets the handler of a job type, jobs of types which are not registered stay in the log
+func (d *DurableQueue) Register(name string, handler JobHandler, options ...JobOption) {
+	registered := &jobType{handler: handler, retryPolicy: DefaultRetryPolicy, visibilityTimeout: defaultVisibilityTimeout}
+	for _, option := range options {
+		option(registered)
+	}
+
+	d.mutex.Lock()
+	d.types[name] = registered
+	d.mutex.Unlock()
+	d.wake()
+}
+
+// Enqueue persists a job of a registered type, it runs as soon as a worker is available
+func (d *DurableQueue) Enqueue(name string, payload []byte) (uint64, error) {
+	return d.EnqueueAfter(name, payload, 0)
+}
+
+// EnqueueAfter persists a job of a registered type which runs once the delay has passed
+func (d *DurableQueue) EnqueueAfter(name string, payload []byte, delay time.Duration) (uint64, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.types[name]; !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownJobType, name)
+	}
+
+	id := d.nextID
+	if err := d.append(jobRecord{Op: opEnqueue, ID: id, Type: name, Payload: payload, At: time.Now().Add(delay)}); err != nil {
+		return 0, err
+	}
+	d.wake()
+	return id, nil
+}
+
+// Pending returns how many jobs are waiting or running, the dead letters are not counted
+func (d *DurableQueue) Pending() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	pending := 0
+	for _, entry := range d.jobs {
+		if !entry.dead {
+			pending++
+		}
+	}
+	return pending
+}
+
+// DeadLetters returns the jobs which have run out of attempts
+func (d *DurableQueue) DeadLetters() []Job {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var jobs []Job
+	for _, entry := range d.jobs {
+		if entry.dead {
+			jobs = append(jobs, entry.Job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// Retry moves a dead letter back into the queue with a fresh set of attempts
+func (d *DurableQueue) Retry(id uint64) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if entry, ok := d.jobs[id]; !ok || !entry.dead {
+		return fmt.Errorf("%w: %d", ErrUnknownJob, id)
+	}
+	if err := d.append(jobRecord{Op: opRevive, ID: id, At: time.Now()}); err != nil {
+		return err
+	}
+	d.wake()
+	return nil
+}
+
+// Discard removes a dead letter for good
+func (d *DurableQueue) Discard(id uint64) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if entry, ok := d.jobs[id]; !ok || !entry.dead {
+		return fmt.Errorf("%w: %d", ErrUnknownJob, id)
+	}
+	return d.append(jobRecord{Op: opAck, ID: id})
+}
+
+func (d *DurableQueue) wake() {
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Start polls for visible jobs and runs them on the pool
+func (d *DurableQueue) Start() {
+	d.started.Do(func() { go d.poll() })
+}
+
+func (d *DurableQueue) poll() {
+	defer close(d.pollerDone)
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch()
+
+		select {
+		case <-ticker.C:
+		case <-d.wakeup:
+		case <-d.closeHandle:
+			return
+		}
+	}
+}
+
+// leasedJob is the Runnable of a started job, attempt tells it apart from a later start of the same job
+type leasedJob struct {
+	queue		*DurableQueue
+	job		Job
+	attempt		int
+	jobType		*jobType
+}
+
+// dispatch leases as many visible jobs as the pool has room for and submits them to the pool
+func (d *DurableQueue) dispatch() {
+	leased := d.lease(d.pool.room())
+	for i, job := range leased {
+		// the rejection policy may run the job on this goroutine, so the mutex is not held
+		if err := d.pool.Execute(job); err != nil {
+			d.release(leased[i:]...)
+			return
+		}
+	}
+}
+
+// lease marks up to limit visible jobs as started until their visibility timeout, at least one job is
+// leased so a rejection policy which blocks or runs the job on the caller still gets it
+func (d *DurableQueue) lease(limit int) []*leasedJob {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	var ids []uint64
+	for id, entry := range d.jobs {
+		if _, ok := d.types[entry.Type]; ok && !entry.dead && !entry.VisibleAt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	if limit < 1 {
+		limit = 1
+	}
+	var records []jobRecord
+	var leased []*leasedJob
+	for _, id := range ids {
+		if len(leased) >= limit {
+			break
+		}
+		entry := d.jobs[id]
+		registered := d.types[entry.Type]
+
+		// a job whose lease has expired counts as a failed attempt
+		if entry.leased && entry.Attempts >= registered.retryPolicy.MaxAttempts {
+			records = append(records, jobRecord{Op: opDead, ID: id, Error: ErrVisibilityTimeout.Error()})
+			continue
+		}
+
+		attempt := entry.Attempts + 1
+		records = append(records, jobRecord{Op: opLease, ID: id, Attempt: attempt, At: now.Add(registered.visibilityTimeout)})
+		leased = append(leased, &leasedJob{queue: d, job: entry.Job, attempt: attempt, jobType: registered})
+	}
+	if len(records) == 0 || d.append(records...) != nil {
+		return nil
+	}
+	d.running.Add(len(leased))
+	return leased
+}
+
+// release hands back the leases of jobs which the pool did not take, without using up an attempt
+func (d *DurableQueue) release(leased ...*leasedJob) {
+	defer d.running.Add(-len(leased))
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var records []jobRecord
+	visibleAt := time.Now().Add(d.pollInterval)
+	for _, l := range leased {
+		if entry, ok := d.jobs[l.job.ID]; ok && entry.leased && entry.Attempts == l.attempt {
+			records = append(records, jobRecord{Op: opRelease, ID: l.job.ID, Attempt: l.attempt - 1, At: visibleAt})
+		}
+	}
+	if len(records) > 0 {
+		_ = d.append(records...)
+	}
+}
+
+func (l *leasedJob) Run() {
+	defer l.queue.running.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.jobType.visibilityTimeout)
+	defer cancel()
+
+	l.queue.finish(l, l.handle(ctx))
+}
+
+// handle runs the handler, a panic fails the attempt like an error
+func (l *leasedJob) handle(ctx context.Context) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = newPanicError(recovered)
+		}
+	}()
+	return l.jobType.handler(ctx, l.job.Payload)
+}
+
+// discard releases the lease of a job which the rejection policy of the pool dropped
+func (l *leasedJob) discard(err error) {
+	l.queue.release(l)
+}
+
+// finish acknowledges the attempt or schedules the next one, unless the job has been started again
+// after its visibility timeout in the meantime
+func (d *DurableQueue) finish(l *leasedJob, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	entry, ok := d.jobs[l.job.ID]
+	if !ok || !entry.leased || entry.Attempts != l.attempt || d.closed {
+		return
+	}
+
+	if err == nil {
+		_ = d.append(jobRecord{Op: opAck, ID: l.job.ID})
+		return
+	}
+	if l.attempt >= l.jobType.retryPolicy.MaxAttempts {
+		_ = d.append(jobRecord{Op: opDead, ID: l.job.ID, Error: err.Error()})
+		return
+	}
+	retryAt := time.Now().Add(l.jobType.retryPolicy.Backoff(l.attempt))
+	_ = d.append(jobRecord{Op: opRetry, ID: l.job.ID, At: retryAt, Error: err.Error()})
+	d.wake()
+}
+
+// Close stops polling, waits for the jobs which have been submitted to the pool and closes the log
+// The pool is not shut down, it has to keep running the submitted jobs until Close returns.
+// Jobs which have not finished run again after the next open.
+func (d *DurableQueue) Close() error {
+	d.mutex.Lock()
+	if d.closing {
+		d.mutex.Unlock()
+		return ErrJobStoreClosed
+	}
+	d.closing = true
+	d.mutex.Unlock()
+
+	close(d.closeHandle)
+	// a queue which was never started has no poller
+	d.started.Do(func() { close(d.pollerDone) })
+	<-d.pollerDone
+	d.running.Wait()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.closed = true
+	if err := d.buffer.Flush(); err != nil {
+		_ = d.file.Close()
+		return err
+	}
+	return d.file.Close()
+}
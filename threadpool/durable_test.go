@@ -0,0 +1,166 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var quickRetries = WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2, Jitter: 0.5})
+
+func openQueue(t *testing.T, path string) (*DurableQueue, *ThreadPool) {
+	t.Helper()
+	pool := NewThreadPool(2, 10)
+	queue, err := OpenDurableQueue(pool, path, WithPollInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return queue, pool
+}
+
+func TestDurableQueueRetries(t *testing.T) {
+	queue, pool := openQueue(t, filepath.Join(t.TempDir(), "jobs.log"))
+	defer pool.Shutdown()
+
+	var calls int32
+	queue.Register("flaky", func(ctx context.Context, payload []byte) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}, quickRetries)
+	queue.Register("broken", func(ctx context.Context, payload []byte) error {
+		panic("boom")
+	}, quickRetries)
+
+	if _, err := queue.Enqueue("unknown", nil); !errors.Is(err, ErrUnknownJobType) {
+		t.Fatalf("expected the job type to be unknown, got %v", err)
+	}
+	_, _ = queue.Enqueue("flaky", nil)
+	broken, _ := queue.Enqueue("broken", []byte("payload"))
+	queue.Start()
+
+	waitFor(t, func() bool { return queue.Pending() == 0 })
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected the flaky job to succeed on its third attempt, got %d calls", calls)
+	}
+
+	dead := queue.DeadLetters()
+	if len(dead) != 1 || dead[0].ID != broken || dead[0].Attempts != 3 || string(dead[0].Payload) != "payload" {
+		t.Fatalf("expected the broken job in the dead letters, got %+v", dead)
+	}
+	if err := queue.Retry(broken); err != nil || queue.Pending() != 1 {
+		t.Fatalf("expected the dead letter back in the queue, got %v", err)
+	}
+	waitFor(t, func() bool { return len(queue.DeadLetters()) == 1 })
+
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDurableQueueSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+	handler := func(ran *int32) JobHandler {
+		return func(ctx context.Context, payload []byte) error {
+			atomic.AddInt32(ran, 1)
+			return nil
+		}
+	}
+
+	var ran int32
+	queue, pool := openQueue(t, path)
+	queue.Register("email", handler(&ran))
+	for i := 0; i < 3; i++ {
+		if _, err := queue.Enqueue("email", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+	pool.Shutdown()
+
+	// a record torn by a crash is dropped on open
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	_, _ = file.Write([]byte{200, 0, 0})
+	_ = file.Close()
+
+	queue, pool = openQueue(t, path)
+	defer pool.Shutdown()
+	if queue.Pending() != 3 {
+		t.Fatalf("expected the 3 jobs after the restart, got %d", queue.Pending())
+	}
+	queue.Register("email", handler(&ran))
+	queue.Start()
+	waitFor(t, func() bool { return queue.Pending() == 0 })
+
+	if err := queue.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); ran != 3 || info.Size() != 0 {
+		t.Fatalf("expected every job to run once and the compacted log to be empty, got %d runs", ran)
+	}
+}
+
+func TestDurableQueueVisibilityTimeout(t *testing.T) {
+	queue, pool := openQueue(t, filepath.Join(t.TempDir(), "jobs.log"))
+	defer pool.Shutdown()
+
+	var calls int32
+	queue.Register("slow", func(ctx context.Context, payload []byte) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// the first attempt hangs until its lease has expired
+			<-ctx.Done()
+			time.Sleep(20 * time.Millisecond)
+		}
+		return nil
+	}, WithVisibilityTimeout(20*time.Millisecond))
+
+	_, _ = queue.Enqueue("slow", nil)
+	queue.Start()
+
+	waitFor(t, func() bool { return queue.Pending() == 0 })
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected the job to start again after its visibility timeout, got %d calls", calls)
+	}
+}
+
+func TestDurableQueueFullPool(t *testing.T) {
+	pool := NewThreadPool(1, 1)
+	defer pool.Shutdown()
+	queue, err := OpenDurableQueue(pool, filepath.Join(t.TempDir(), "jobs.log"), WithPollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ran int32
+	queue.Register("slow", func(ctx context.Context, payload []byte) error {
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&ran, 1)
+		return nil
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	for i := 0; i < 10; i++ {
+		_, _ = queue.Enqueue("slow", nil)
+	}
+	queue.Start()
+
+	// the jobs which do not fit into the pool are not leased, so none of them runs out of attempts
+	waitFor(t, func() bool { return queue.Pending() == 0 })
+	if atomic.LoadInt32(&ran) != 10 || len(queue.DeadLetters()) != 0 {
+		t.Fatalf("expected all jobs to run once, got %d runs and %+v", ran, queue.DeadLetters())
+	}
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
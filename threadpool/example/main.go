@@ -11,6 +11,11 @@ func main() {
 	time.Sleep(20 * time.Second)
 	task := &myTask{ID: 123}
 	pool.Execute(task)
+
+	time.Sleep(time.Second)
+	stats := pool.Stats()
+	fmt.Printf("%d of %d workers busy, %d tasks queued, %d completed, p99 wait %v\n",
+		stats.ActiveWorkers, stats.PoolSize, stats.QueueDepth, stats.Completed, stats.QueueWait.Percentile(0.99))
 }
 
 type myTask struct {
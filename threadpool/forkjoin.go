@@ -0,0 +1,315 @@
+package threadpool
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// RecursiveTask is a divide and conquer computation for the ForkJoinPool
+// Compute splits the work with Fork and combines the results with Join, both on the given worker
+type RecursiveTask[T any] interface {
+	Compute(w *ForkJoinWorker) T
+}
+
+// RecursiveFunc lets ordinary functions be used as RecursiveTask
+type RecursiveFunc[T any] func(w *ForkJoinWorker) T
+
+func (r RecursiveFunc[T]) Compute(w *ForkJoinWorker) T {
+	return r(w)
+}
+
+// ForkJoinPool runs recursive tasks on a fixed number of workers which each own a deque of tasks
+// A worker pushes and pops the tasks it forks at the tail of its deque, idle workers steal the
+// oldest tasks from the head of the other deques. A worker waiting in Join runs other tasks meanwhile.
+type ForkJoinPool struct {
+	workers		[]*ForkJoinWorker
+	// tasks submitted from outside of the pool, taken in FIFO order
+	submissions	workDeque
+
+	mutex		sync.Mutex
+	workAvailable	*sync.Cond
+	// changes whenever a task is pushed, lets parking workers notice work they have missed
+	version		uint64
+	idle		int32
+	state		int32
+	running		sync.WaitGroup
+	terminated	chan struct{}
+
+	steals		int64
+}
+
+// ForkJoinWorker is the context a RecursiveTask runs in
+type ForkJoinWorker struct {
+	pool		*ForkJoinPool
+	deque		workDeque
+	seed		uint32
+}
+
+// forkJoinTask is the untyped side of a ForkJoinTask which the deques hold
+type forkJoinTask interface {
+	exec(w *ForkJoinWorker)
+}
+
+// workDeque is a double ended queue of tasks, its owner works at the tail and thieves at the head
+type workDeque struct {
+	mutex		sync.Mutex
+	tasks		[]forkJoinTask
+	head		int
+}
+
+func (d *workDeque) push(task forkJoinTask) {
+	d.mutex.Lock()
+	d.tasks = append(d.tasks, task)
+	d.mutex.Unlock()
+}
+
+func (d *workDeque) pop() forkJoinTask {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(d.tasks) == d.head {
+		return nil
+	}
+	task := d.tasks[len(d.tasks)-1]
+	d.tasks[len(d.tasks)-1] = nil
+	d.tasks = d.tasks[:len(d.tasks)-1]
+	d.reset()
+	return task
+}
+
+func (d *workDeque) steal() forkJoinTask {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(d.tasks) == d.head {
+		return nil
+	}
+	task := d.tasks[d.head]
+	d.tasks[d.head] = nil
+	d.head++
+	d.reset()
+	return task
+}
+
+// reset reuses the slice once the deque is empty
+func (d *workDeque) reset() {
+	if len(d.tasks) == d.head {
+		d.tasks, d.head = d.tasks[:0], 0
+	}
+}
+
+// NewForkJoinPool starts the workers, parallelism defaults to GOMAXPROCS
+func NewForkJoinPool(parallelism int) *ForkJoinPool {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+
+	pool := &ForkJoinPool{terminated: make(chan struct{})}
+	pool.workAvailable = sync.NewCond(&pool.mutex)
+	for i := 0; i < parallelism; i++ {
+		pool.workers = append(pool.workers, &ForkJoinWorker{pool: pool, seed: uint32(i)*2654435761 + 1})
+	}
+
+	pool.running.Add(parallelism)
+	for _, worker := range pool.workers {
+		go worker.run()
+	}
+	go func() {
+		pool.running.Wait()
+		atomic.StoreInt32(&pool.state, stateTerminated)
+		close(pool.terminated)
+	}()
+	return pool
+}
+
+// Parallelism returns the number of workers
+func (p *ForkJoinPool) Parallelism() int {
+	return len(p.workers)
+}
+
+// StealCount returns how many tasks have been taken from the deque of another worker
+func (p *ForkJoinPool) StealCount() int64 {
+	return atomic.LoadInt64(&p.steals)
+}
+
+// signal wakes an idle worker after a task was pushed
+func (p *ForkJoinPool) signal() {
+	atomic.AddUint64(&p.version, 1)
+	if atomic.LoadInt32(&p.idle) > 0 {
+		p.mutex.Lock()
+		p.workAvailable.Signal()
+		p.mutex.Unlock()
+	}
+}
+
+func (w *ForkJoinWorker) run() {
+	defer w.pool.running.Done()
+
+	for {
+		version := atomic.LoadUint64(&w.pool.version)
+		if task := w.next(); task != nil {
+			task.exec(w)
+			continue
+		}
+		if !w.park(version) {
+			return
+		}
+	}
+}
+
+// park waits until a task is pushed after the given version, it returns false once the pool is shut down
+func (w *ForkJoinWorker) park(version uint64) bool {
+	p := w.pool
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	atomic.AddInt32(&p.idle, 1)
+	defer atomic.AddInt32(&p.idle, -1)
+
+	for atomic.LoadUint64(&p.version) == version {
+		if atomic.LoadInt32(&p.state) != stateRunning {
+			return false
+		}
+		p.workAvailable.Wait()
+	}
+	return true
+}
+
+// next returns the newest local task, else the oldest task of another worker or of the submissions
+func (w *ForkJoinWorker) next() forkJoinTask {
+	if task := w.deque.pop(); task != nil {
+		return task
+	}
+
+	workers := w.pool.workers
+	start := int(w.random() % uint32(len(workers)))
+	for i := range workers {
+		victim := workers[(start+i)%len(workers)]
+		if victim == w {
+			continue
+		}
+		if task := victim.deque.steal(); task != nil {
+			atomic.AddInt64(&w.pool.steals, 1)
+			return task
+		}
+	}
+
+	return w.pool.submissions.steal()
+}
+
+// random is a xorshift generator which spreads the thieves over the deques
+func (w *ForkJoinWorker) random() uint32 {
+	w.seed ^= w.seed << 13
+	w.seed ^= w.seed >> 17
+	w.seed ^= w.seed << 5
+	return w.seed
+}
+
+// ForkJoinTask is a forked RecursiveTask, it runs at most once on whichever worker takes it first
+type ForkJoinTask[T any] struct {
+	task		RecursiveTask[T]
+	claimed		int32
+	future		*Future[T]
+}
+
+func newForkJoinTask[T any](task RecursiveTask[T]) *ForkJoinTask[T] {
+	return &ForkJoinTask[T]{task: task, future: newFuture[T]()}
+}
+
+func (f *ForkJoinTask[T]) exec(w *ForkJoinWorker) {
+	if !atomic.CompareAndSwapInt32(&f.claimed, 0, 1) || f.future.IsDone() {
+		return
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			var empty T
+			f.future.complete(empty, newPanicError(recovered))
+		}
+	}()
+	f.future.complete(f.task.Compute(w), nil)
+}
+
+// Fork pushes the task onto the deque of the worker, it runs later on this worker or is stolen
+func Fork[T any](w *ForkJoinWorker, task RecursiveTask[T]) *ForkJoinTask[T] {
+	forked := newForkJoinTask[T](task)
+	w.deque.push(forked)
+	w.pool.signal()
+	return forked
+}
+
+// Join returns the result of the forked task, the worker runs other tasks until it is done
+// It must be called from the Compute of a task running on the worker
+// A task which panicked panics again in Join, so the panic travels up to the submitted task
+func (f *ForkJoinTask[T]) Join(w *ForkJoinWorker) T {
+	for !f.future.IsDone() {
+		// Most of the time the task is still at the tail of the local deque and runs right away
+		if task := w.next(); task != nil {
+			task.exec(w)
+			continue
+		}
+		// Nothing left to help with, the task is running on another worker
+		<-f.future.done
+	}
+
+	if f.future.err != nil {
+		panic(f.future.err)
+	}
+	return f.future.result
+}
+
+// SubmitRecursive queues the task on the pool and returns the future of its result
+func SubmitRecursive[T any](p *ForkJoinPool, task RecursiveTask[T]) (*Future[T], error) {
+	// Checking the state and pushing under the mutex keeps a parking worker from exiting in between
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if atomic.LoadInt32(&p.state) != stateRunning {
+		return nil, ErrPoolShutdown
+	}
+
+	submitted := newForkJoinTask[T](task)
+	p.submissions.push(submitted)
+	atomic.AddUint64(&p.version, 1)
+	p.workAvailable.Signal()
+	return submitted.future, nil
+}
+
+// InvokeRecursive runs the task on the pool and waits for its result
+func InvokeRecursive[T any](p *ForkJoinPool, task RecursiveTask[T]) (T, error) {
+	future, err := SubmitRecursive[T](p, task)
+	if err != nil {
+		var empty T
+		return empty, err
+	}
+	return future.Get(context.Background())
+}
+
+// Shutdown stops accepting tasks, the workers exit once every queued task has run
+func (p *ForkJoinPool) Shutdown() {
+	p.mutex.Lock()
+	atomic.CompareAndSwapInt32(&p.state, stateRunning, stateShutdown)
+	p.workAvailable.Broadcast()
+	p.mutex.Unlock()
+}
+
+// AwaitTermination blocks until the workers have exited after a shutdown, or until the context is done
+func (p *ForkJoinPool) AwaitTermination(ctx context.Context) error {
+	select {
+	case <-p.terminated:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsShutdown returns true once Shutdown has been called
+func (p *ForkJoinPool) IsShutdown() bool {
+	return atomic.LoadInt32(&p.state) != stateRunning
+}
+
+// IsTerminated returns true once the workers have exited after a shutdown
+func (p *ForkJoinPool) IsTerminated() bool {
+	return atomic.LoadInt32(&p.state) == stateTerminated
+}
@@ -0,0 +1,81 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sumTask adds up the numbers by splitting them in halves until they are small enough
+type sumTask struct {
+	numbers []int64
+	// lets the leaves take long enough for the other workers to steal on a single CPU
+	pause time.Duration
+}
+
+func (s sumTask) Compute(w *ForkJoinWorker) int64 {
+	if len(s.numbers) <= 1000 {
+		time.Sleep(s.pause)
+		var sum int64
+		for _, number := range s.numbers {
+			sum += number
+		}
+		return sum
+	}
+
+	middle := len(s.numbers) / 2
+	left := Fork[int64](w, sumTask{numbers: s.numbers[:middle], pause: s.pause})
+	right := sumTask{numbers: s.numbers[middle:], pause: s.pause}.Compute(w)
+	return left.Join(w) + right
+}
+
+func TestForkJoinSum(t *testing.T) {
+	pool := NewForkJoinPool(4)
+
+	numbers := make([]int64, 1000000)
+	for i := range numbers {
+		numbers[i] = int64(i + 1)
+	}
+
+	sum, err := InvokeRecursive[int64](pool, sumTask{numbers: numbers, pause: 100 * time.Microsecond})
+	if err != nil || sum != 500000500000 {
+		t.Fatalf("expected 500000500000, got %d %v", sum, err)
+	}
+	if pool.StealCount() == 0 {
+		t.Fatal("expected the idle workers to steal forked tasks")
+	}
+
+	pool.Shutdown()
+	if _, err := InvokeRecursive[int64](pool, sumTask{}); err != ErrPoolShutdown {
+		t.Fatalf("expected the pool to be shut down, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForkJoinPanic(t *testing.T) {
+	pool := NewForkJoinPool(2)
+	defer pool.Shutdown()
+
+	var fibonacci func(n int) RecursiveFunc[int]
+	fibonacci = func(n int) RecursiveFunc[int] {
+		return func(w *ForkJoinWorker) int {
+			if n == 3 {
+				panic("boom")
+			}
+			if n < 2 {
+				return n
+			}
+			left := Fork[int](w, fibonacci(n-1))
+			return fibonacci(n-2).Compute(w) + left.Join(w)
+		}
+	}
+
+	if _, err := InvokeRecursive[int](pool, fibonacci(15)); !errors.Is(err, ErrTaskPanicked) {
+		t.Fatalf("expected the panic of a subtask to reach the caller, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package threadpool
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"garry.org/data_structure/priorityqueue"
+)
+
+var ErrNoTasks = fmt.Errorf("there are no tasks to invoke")
+
+// cancelAll cancels the futures which are not done yet
+func cancelAll[T any](futures []*Future[T]) {
+	for _, future := range futures {
+		future.Cancel()
+	}
+}
+
+// InvokeAll submits every task and waits until all of them are done, the futures are in the order of
+// the tasks. When a task is rejected or the context is done first, the pending tasks are cancelled.
+func InvokeAll[T any](ctx context.Context, t *ThreadPool, tasks []Callable[T]) ([]*Future[T], error) {
+	futures := make([]*Future[T], 0, len(tasks))
+	for _, task := range tasks {
+		future, err := Submit[T](t, task)
+		if err != nil {
+			cancelAll(futures)
+			return nil, err
+		}
+		futures = append(futures, future)
+	}
+
+	for _, future := range futures {
+		select {
+		case <-future.done:
+		case <-ctx.Done():
+			cancelAll(futures)
+			return futures, ctx.Err()
+		}
+	}
+	return futures, nil
+}
+
+// InvokeAny submits every task and returns the result of the first one which succeeds, the other tasks
+// are cancelled. It returns the error of the last task to fail when none of them succeeds.
+func InvokeAny[T any](ctx context.Context, t *ThreadPool, tasks []Callable[T]) (T, error) {
+	var empty T
+	if len(tasks) == 0 {
+		return empty, ErrNoTasks
+	}
+
+	completed := make(chan *Future[T], len(tasks))
+	futures := make([]*Future[T], 0, len(tasks))
+	defer func() { cancelAll(futures) }()
+
+	var lastErr error
+	for _, task := range tasks {
+		future, err := Submit[T](t, task)
+		if err != nil {
+			// the tasks which have been submitted may still succeed
+			lastErr = err
+			break
+		}
+		futures = append(futures, future)
+		future.onComplete(func() { completed <- future })
+	}
+
+	for range futures {
+		select {
+		case future := <-completed:
+			if future.err == nil {
+				return future.result, nil
+			}
+			lastErr = future.err
+		case <-ctx.Done():
+			return empty, ctx.Err()
+		}
+	}
+	return empty, lastErr
+}
+
+// CompletionService submits tasks to a pool and hands out their futures in the order they complete
+type CompletionService[T any] struct {
+	pool		*ThreadPool
+	completed	*priorityqueue.BlockingPriorityQueue[*Future[T]]
+}
+
+func NewCompletionService[T any](pool *ThreadPool) *CompletionService[T] {
+	return &CompletionService[T]{
+		pool:      pool,
+		completed: priorityqueue.NewBlockingPriorityQueue[*Future[T]](math.MaxInt),
+	}
+}
+
+// Submit queues the task on the pool, its future can be taken once it is done
+func (c *CompletionService[T]) Submit(task Callable[T]) (*Future[T], error) {
+	future, err := Submit[T](c.pool, task)
+	if err != nil {
+		return nil, err
+	}
+	future.onComplete(func() {
+		c.completed.Offer(future, 0)
+	})
+	return future, nil
+}
+
+// Take returns the next future which is done, it blocks until there is one or the context is done
+func (c *CompletionService[T]) Take(ctx context.Context) (*Future[T], error) {
+	return c.completed.Take(ctx)
+}
+
+// Poll returns the next future which is done, nil if there is none yet
+func (c *CompletionService[T]) Poll() *Future[T] {
+	future, _ := c.completed.Poll()
+	return future
+}
@@ -0,0 +1,82 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sleepy returns a callable which answers the value after the delay
+func sleepy(value int, delay time.Duration, err error) Callable[int] {
+	return CallableFunc[int](func() (int, error) {
+		time.Sleep(delay)
+		return value, err
+	})
+}
+
+func TestInvokeAll(t *testing.T) {
+	pool := NewThreadPool(3, 10)
+	defer pool.Shutdown()
+
+	futures, err := InvokeAll[int](context.Background(), pool, []Callable[int]{
+		sleepy(1, 20*time.Millisecond, nil), sleepy(2, 0, nil), sleepy(3, 10*time.Millisecond, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, future := range futures {
+		if value, _ := future.Get(context.Background()); value != i+1 {
+			t.Fatalf("expected the futures in the order of the tasks, got %d at %d", value, i)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	futures, err = InvokeAll[int](ctx, pool, []Callable[int]{sleepy(1, 0, nil), sleepy(2, time.Second, nil)})
+	if err != context.DeadlineExceeded || !futures[1].IsCancelled() {
+		t.Fatalf("expected the slow task to be cancelled, got %v", err)
+	}
+}
+
+func TestInvokeAny(t *testing.T) {
+	pool := NewThreadPool(3, 10)
+	defer pool.Shutdown()
+
+	failure := errors.New("failure")
+	value, err := InvokeAny[int](context.Background(), pool, []Callable[int]{
+		sleepy(1, time.Second, nil), sleepy(2, 0, failure), sleepy(3, 10*time.Millisecond, nil),
+	})
+	if value != 3 || err != nil {
+		t.Fatalf("expected the first successful result, got %d %v", value, err)
+	}
+
+	if _, err = InvokeAny[int](context.Background(), pool, []Callable[int]{sleepy(1, 0, failure)}); err != failure {
+		t.Fatalf("expected the error of the failed task, got %v", err)
+	}
+}
+
+func TestCompletionService(t *testing.T) {
+	pool := NewThreadPool(3, 10)
+	defer pool.Shutdown()
+
+	service := NewCompletionService[int](pool)
+	for _, delay := range []int{30, 10, 20} {
+		if _, err := service.Submit(sleepy(delay, time.Duration(delay)*time.Millisecond, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []int{10, 20, 30} {
+		future, err := service.Take(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, _ := future.Get(context.Background()); value != expected {
+			t.Fatalf("expected the futures in completion order, got %d instead of %d", value, expected)
+		}
+	}
+	if service.Poll() != nil {
+		t.Fatal("expected no more completed futures")
+	}
+}
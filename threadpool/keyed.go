@@ -0,0 +1,172 @@
+package threadpool
+
+import (
+	"fmt"
+	"sync"
+)
+
+var ErrLaneFull = fmt.Errorf("too many tasks are queued for the key")
+
+// KeyedExecutor runs the tasks of the same key one after the other in submission order, while the
+// tasks of different keys run in parallel on the pool. Each key with queued tasks has a lane which
+// occupies at most one worker at a time, a lane is created on demand and dropped once it is empty.
+type KeyedExecutor[K comparable] struct {
+	pool		*ThreadPool
+	// zero means no limit
+	maxQueuedPerKey	int
+	mutex		sync.Mutex
+	lanes		map[K]*lane[K]
+}
+
+// lane holds the queued tasks of a key, it is queued on the pool as a Runnable while it has tasks
+type lane[K comparable] struct {
+	executor	*KeyedExecutor[K]
+	key		K
+	tasks		[]Runnable
+	scheduled	bool
+}
+
+// NewKeyedExecutor runs the lanes on the pool, maxQueuedPerKey bounds the tasks waiting for a key
+func NewKeyedExecutor[K comparable](pool *ThreadPool, maxQueuedPerKey int) *KeyedExecutor[K] {
+	return &KeyedExecutor[K]{pool: pool, maxQueuedPerKey: maxQueuedPerKey, lanes: make(map[K]*lane[K])}
+}
+
+// Execute queues the task behind the other tasks of the key
+// When the pool rejects the lane of the key the task is removed again and the error is returned.
+// The tasks which other calls have queued in the meantime still run, on this goroutine when the
+// pool does not take the lane.
+func (k *KeyedExecutor[K]) Execute(key K, task Runnable) error {
+	k.mutex.Lock()
+	current, ok := k.lanes[key]
+	if !ok {
+		current = &lane[K]{executor: k, key: key}
+		k.lanes[key] = current
+	}
+	if k.maxQueuedPerKey > 0 && len(current.tasks) >= k.maxQueuedPerKey {
+		k.mutex.Unlock()
+		return ErrLaneFull
+	}
+	current.tasks = append(current.tasks, task)
+	// the lane does not run before it is queued, so the task keeps its index until the pool rejects it
+	index := len(current.tasks) - 1
+
+	if current.scheduled {
+		k.mutex.Unlock()
+		return nil
+	}
+	current.scheduled = true
+	k.mutex.Unlock()
+
+	// the rejection policy may run the lane on this goroutine, so the mutex is not held
+	err := k.pool.Execute(current)
+	if err != nil {
+		k.mutex.Lock()
+		current.tasks = append(current.tasks[:index:index], current.tasks[index+1:]...)
+		remaining := len(current.tasks) > 0
+		if !remaining {
+			current.scheduled = false
+			k.reclaim(current)
+		}
+		k.mutex.Unlock()
+
+		// the other calls saw the lane scheduled and have returned, nobody else runs their tasks
+		if remaining && !k.pool.requeue(current) {
+			current.Run()
+		}
+	}
+	return err
+}
+
+// SubmitKeyed queues the callable behind the other tasks of the key and returns its future
+func SubmitKeyed[K comparable, T any](k *KeyedExecutor[K], key K, task Callable[T]) (*Future[T], error) {
+	futureTask := callableTask[T]{Task: task, Handle: newFuture[T]()}
+	if err := k.Execute(key, futureTask); err != nil {
+		return nil, err
+	}
+	return futureTask.Handle, nil
+}
+
+// Lanes returns how many keys have tasks queued or running
+func (k *KeyedExecutor[K]) Lanes() int {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return len(k.lanes)
+}
+
+// reclaim drops the lane once it is empty, it is called with the mutex held
+func (k *KeyedExecutor[K]) reclaim(l *lane[K]) {
+	if len(l.tasks) == 0 && k.lanes[l.key] == l {
+		delete(k.lanes, l.key)
+	}
+}
+
+// Run runs the task at the head of the lane, then queues the lane on the pool again so that other
+// keys get their turn. When the pool does not take the lane back it keeps running on this worker.
+func (l *lane[K]) Run() {
+	k := l.executor
+	for {
+		k.mutex.Lock()
+		task := l.tasks[0]
+		k.mutex.Unlock()
+
+		l.run(task)
+
+		k.mutex.Lock()
+		l.tasks[0] = nil
+		l.tasks = l.tasks[1:]
+		if len(l.tasks) == 0 {
+			l.scheduled = false
+			k.reclaim(l)
+			k.mutex.Unlock()
+			return
+		}
+		k.mutex.Unlock()
+
+		if k.pool.requeue(l) {
+			return
+		}
+	}
+}
+
+// run runs the task, after a panic the rest of the lane is queued on the pool again before the
+// panic reaches the worker. When the pool does not take the lane back the rest runs on this worker.
+func (l *lane[K]) run(task Runnable) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			k := l.executor
+			k.mutex.Lock()
+			l.tasks[0] = nil
+			l.tasks = l.tasks[1:]
+			remaining := len(l.tasks) > 0
+			if !remaining {
+				l.scheduled = false
+				k.reclaim(l)
+			}
+			k.mutex.Unlock()
+
+			if remaining && !k.pool.requeue(l) {
+				l.Run()
+			}
+			panic(recovered)
+		}
+	}()
+
+	task.Run()
+}
+
+// discard lets the tasks of a lane which the rejection policy dropped tell their submitters
+func (l *lane[K]) discard(err error) {
+	k := l.executor
+	k.mutex.Lock()
+	tasks := l.tasks
+	l.tasks = nil
+	l.scheduled = false
+	k.reclaim(l)
+	k.mutex.Unlock()
+
+	for _, task := range tasks {
+		if item, ok := task.(discardable); ok {
+			item.discard(err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package threadpool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyedExecutorOrdersTasksPerKey(t *testing.T) {
+	pool := NewThreadPool(4, 100)
+	keyed := NewKeyedExecutor[string](pool, 0)
+
+	var mutex sync.Mutex
+	var running = make(map[string]*int32)
+	var order = make(map[string][]int)
+	for _, key := range []string{"a", "b", "c"} {
+		running[key] = new(int32)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, key := range []string{"a", "b", "c"} {
+			key, i := key, i
+			wg.Add(1)
+			err := keyed.Execute(key, RunnableFunc(func() {
+				defer wg.Done()
+				if atomic.AddInt32(running[key], 1) != 1 {
+					t.Errorf("tasks of %s ran concurrently", key)
+				}
+				mutex.Lock()
+				order[key] = append(order[key], i)
+				mutex.Unlock()
+				atomic.AddInt32(running[key], -1)
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	wg.Wait()
+
+	for key, sequence := range order {
+		for i, value := range sequence {
+			if value != i {
+				t.Fatalf("expected the tasks of %s in submission order, got %v", key, sequence)
+			}
+		}
+	}
+	waitFor(t, func() bool { return keyed.Lanes() == 0 })
+	pool.Shutdown()
+}
+
+func TestKeyedExecutorBound(t *testing.T) {
+	pool := NewThreadPool(2, 10)
+	defer pool.Shutdown()
+	keyed := NewKeyedExecutor[int](pool, 2)
+
+	release := make(chan struct{})
+	_ = keyed.Execute(1, RunnableFunc(func() { <-release }))
+	future, err := SubmitKeyed[int, string](keyed, 1, CallableFunc[string](func() (string, error) { return "done", nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyed.Execute(1, RunnableFunc(func() {})); err != ErrLaneFull {
+		t.Fatalf("expected the lane to be full, got %v", err)
+	}
+	if err := keyed.Execute(2, RunnableFunc(func() {})); err != nil {
+		t.Fatalf("expected the other keys not to be bounded by the full lane, got %v", err)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if value, err := future.Get(ctx); value != "done" || err != nil {
+		t.Fatalf("expected the queued task to run, got %s %v", value, err)
+	}
+}
+
+// fill queues no-op tasks until the pool rejects one, the busy worker keeps them from running
+func fill(t *testing.T, pool *ThreadPool) {
+	t.Helper()
+	for i := 0; ; i++ {
+		if err := pool.Execute(RunnableFunc(func() {})); err == ErrQueueFull {
+			return
+		} else if i > 10 {
+			t.Fatal("expected the pool to reject a task")
+		}
+	}
+}
+
+func TestKeyedExecutorRejected(t *testing.T) {
+	pool := NewThreadPool(1, 1)
+	defer pool.Shutdown()
+	keyed := NewKeyedExecutor[string](pool, 0)
+
+	release := make(chan struct{})
+	_ = keyed.Execute("a", RunnableFunc(func() { <-release }))
+	waitFor(t, func() bool { return pool.Stats().ActiveWorkers == 1 })
+	fill(t, pool)
+
+	if err := keyed.Execute("b", RunnableFunc(func() {})); err != ErrQueueFull {
+		t.Fatalf("expected the lane to be rejected, got %v", err)
+	}
+	if _, err := SubmitKeyed[string, int](keyed, "b", CallableFunc[int](func() (int, error) { return 1, nil })); err != ErrQueueFull {
+		t.Fatalf("expected the lane to be rejected, got %v", err)
+	}
+	close(release)
+	waitFor(t, func() bool { return keyed.Lanes() == 0 })
+}
+
+func TestKeyedExecutorPanicWithFullPool(t *testing.T) {
+	pool := NewThreadPool(1, 1, WithPanicHandler(PanicHandlerFunc(func(Runnable, *PanicError) {})))
+	defer pool.Shutdown()
+	keyed := NewKeyedExecutor[string](pool, 0)
+
+	release := make(chan struct{})
+	_ = keyed.Execute("a", RunnableFunc(func() {
+		<-release
+		panic("boom")
+	}))
+	future, _ := SubmitKeyed[string, string](keyed, "a", CallableFunc[string](func() (string, error) { return "next", nil }))
+	waitFor(t, func() bool { return pool.Stats().ActiveWorkers == 1 })
+	fill(t, pool)
+
+	// the lane cannot be queued again after the panic, so the next task runs on the same worker
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if value, err := future.Get(ctx); value != "next" || err != nil {
+		t.Fatalf("expected the task behind the panic to run, got %s %v", value, err)
+	}
+}
+
+func TestKeyedExecutorRejectedWithConcurrentTask(t *testing.T) {
+	pool := NewThreadPool(1, 1, WithRejectedExecutionHandler(BlockPolicy{Timeout: 50 * time.Millisecond}))
+	defer pool.Shutdown()
+	keyed := NewKeyedExecutor[string](pool, 0)
+
+	release := make(chan struct{})
+	defer close(release)
+	_ = pool.Execute(RunnableFunc(func() { <-release }))
+	waitFor(t, func() bool { return pool.Stats().ActiveWorkers == 1 })
+	// the dispatcher holds one task while it waits for the worker, the queue holds another
+	for i := 0; i < 3; i++ {
+		for pool.offer(RunnableFunc(func() {})) {
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	rejected := make(chan error, 1)
+	go func() { rejected <- keyed.Execute("a", RunnableFunc(func() {})) }()
+	waitFor(t, func() bool { return keyed.Lanes() == 1 })
+
+	// queued behind the task which the pool is about to reject
+	ran := make(chan struct{})
+	if err := keyed.Execute("a", RunnableFunc(func() { close(ran) })); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-rejected; err != ErrQueueFull {
+		t.Fatalf("expected the first task to be rejected, got %v", err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected the accepted task to run")
+	}
+	waitFor(t, func() bool { return keyed.Lanes() == 0 })
+}
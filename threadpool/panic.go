@@ -0,0 +1,50 @@
+package threadpool
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+var ErrTaskPanicked = fmt.Errorf("the task panicked")
+
+// PanicError is the error of a future whose task panicked, it unwraps to ErrTaskPanicked
+type PanicError struct {
+	Value		interface{}
+	Stack		[]byte
+}
+
+func newPanicError(recovered interface{}) *PanicError {
+	if err, ok := recovered.(*PanicError); ok {
+		return err
+	}
+	return &PanicError{Value: recovered, Stack: debug.Stack()}
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrTaskPanicked, p.Value)
+}
+
+func (p *PanicError) Unwrap() error {
+	return ErrTaskPanicked
+}
+
+// PanicHandler is told about every task which panicked on a worker of the pool
+// The worker which ran the task is replaced, the handler runs before the replacement starts
+type PanicHandler interface {
+	HandlePanic(task Runnable, err *PanicError)
+}
+
+// PanicHandlerFunc lets ordinary functions be used as PanicHandler
+type PanicHandlerFunc func(task Runnable, err *PanicError)
+
+func (p PanicHandlerFunc) HandlePanic(task Runnable, err *PanicError) {
+	p(task, err)
+}
+
+// logPanicHandler is the default PanicHandler, it writes the panic and its stack to the standard logger
+type logPanicHandler struct{}
+
+func (logPanicHandler) HandlePanic(task Runnable, err *PanicError) {
+	log.Printf("threadpool: %v\n%s", err, err.Stack)
+}
@@ -0,0 +1,54 @@
+package threadpool
+
+// Prioritized is implemented by tasks which should not wait behind the tasks queued before them,
+// the pool dispatches the pending task with the highest priority first. Tasks of the same priority
+// run in submission order, tasks which do not implement it have a priority of zero.
+type Prioritized interface {
+	Priority() int
+}
+
+func priorityOf(task interface{}) int {
+	if item, ok := task.(Prioritized); ok {
+		return item.Priority()
+	}
+	return 0
+}
+
+type prioritizedRunnable struct {
+	Runnable
+	priority	int
+}
+
+func (p prioritizedRunnable) Priority() int {
+	return p.priority
+}
+
+func (p prioritizedRunnable) discard(err error) {
+	if item, ok := p.Runnable.(discardable); ok {
+		item.discard(err)
+	}
+}
+
+// Prioritize gives the task a priority, e.g. to let user facing requests jump ahead of batch work
+func Prioritize(task Runnable, priority int) Runnable {
+	return prioritizedRunnable{Runnable: task, priority: priority}
+}
+
+type prioritizedCallable[T any] struct {
+	Callable[T]
+	priority	int
+}
+
+func (p prioritizedCallable[T]) Priority() int {
+	return p.priority
+}
+
+// PrioritizeCallable gives the callable a priority, see Prioritize
+func PrioritizeCallable[T any](task Callable[T], priority int) Callable[T] {
+	return prioritizedCallable[T]{Callable: task, priority: priority}
+}
+
+// Priority lets callable tasks carry the priority of their callable
+func (c callableTask[T]) Priority() int {
+	return priorityOf(c.Task)
+}
@@ -0,0 +1,66 @@
+package threadpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queueInOrder blocks the single worker of the pool, queues the tasks and returns the order they ran in
+func queueInOrder(t *testing.T, pool *ThreadPool, queue func(record func(name string) Runnable)) []string {
+	release := make(chan struct{})
+	_ = pool.Execute(RunnableFunc(func() { <-release }))
+	// the dispatcher holds the filler while it waits for the worker
+	_ = pool.Execute(RunnableFunc(func() {}))
+	waitFor(t, func() bool { return pool.jobQueue.Len() == 0 })
+
+	var mutex sync.Mutex
+	var order []string
+	queue(func(name string) Runnable {
+		return RunnableFunc(func() {
+			mutex.Lock()
+			order = append(order, name)
+			mutex.Unlock()
+		})
+	})
+
+	close(release)
+	pool.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestPriorityQueueing(t *testing.T) {
+	pool := NewThreadPool(1, 10)
+	order := queueInOrder(t, pool, func(record func(name string) Runnable) {
+		_ = pool.Execute(record("batch 1"))
+		_ = pool.Execute(record("batch 2"))
+		_ = pool.Execute(Prioritize(record("user"), 10))
+		_, _ = Submit[int](pool, PrioritizeCallable[int](CallableFunc[int](func() (int, error) {
+			record("urgent").Run()
+			return 0, nil
+		}), 20))
+	})
+
+	if len(order) != 4 || order[0] != "urgent" || order[1] != "user" || order[2] != "batch 1" || order[3] != "batch 2" {
+		t.Fatalf("expected the tasks by priority, got %v", order)
+	}
+}
+
+func TestPriorityAging(t *testing.T) {
+	pool := NewThreadPool(1, 10, WithAging(time.Millisecond))
+	order := queueInOrder(t, pool, func(record func(name string) Runnable) {
+		_ = pool.Execute(record("batch"))
+		time.Sleep(20 * time.Millisecond)
+		_ = pool.Execute(Prioritize(record("user"), 5))
+	})
+
+	if len(order) != 2 || order[0] != "batch" {
+		t.Fatalf("expected the waiting batch task to have aged past the user task, got %v", order)
+	}
+}
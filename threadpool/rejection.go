@@ -0,0 +1,88 @@
+package threadpool
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTaskDiscarded = fmt.Errorf("the task was discarded because the queue is full")
+)
+
+// RejectedExecutionHandler decides what happens to a task which does not fit into the full queue of the pool
+type RejectedExecutionHandler interface {
+	RejectedExecution(task Runnable, pool *ThreadPool) error
+}
+
+// discardable is implemented by tasks which have to tell their submitter that they will not run
+type discardable interface {
+	discard(err error)
+}
+
+func (c callableTask[T]) discard(err error) {
+	var empty T
+	c.Handle.complete(empty, err)
+}
+
+func discard(task Runnable) {
+	if item, ok := task.(discardable); ok {
+		item.discard(ErrTaskDiscarded)
+	}
+}
+
+// AbortPolicy rejects the task with ErrQueueFull, this is the default policy
+type AbortPolicy struct{}
+
+func (AbortPolicy) RejectedExecution(task Runnable, pool *ThreadPool) error {
+	return ErrQueueFull
+}
+
+// CallerRunsPolicy runs the task on the goroutine which submitted it, this slows the submitter down
+type CallerRunsPolicy struct{}
+
+func (CallerRunsPolicy) RejectedExecution(task Runnable, pool *ThreadPool) error {
+	task.Run()
+	return nil
+}
+
+// DiscardPolicy drops the task, the future of a callable task fails with ErrTaskDiscarded
+type DiscardPolicy struct{}
+
+func (DiscardPolicy) RejectedExecution(task Runnable, pool *ThreadPool) error {
+	discard(task)
+	return nil
+}
+
+// DiscardOldestPolicy drops the task which would be dispatched next to make room for the task,
+// that is the one which has been queued the longest unless the tasks have priorities
+// It tries once, the task is rejected with ErrQueueFull when another submitter takes the room
+// first or when there is nothing to drop, e.g. with a queue size of zero.
+type DiscardOldestPolicy struct{}
+
+func (DiscardOldestPolicy) RejectedExecution(task Runnable, pool *ThreadPool) error {
+	if pool.IsShutdown() {
+		return ErrPoolShutdown
+	}
+	if oldest := pool.pollNext(); oldest != nil {
+		discard(oldest)
+	}
+	if pool.offer(task) {
+		return nil
+	}
+	return ErrQueueFull
+}
+
+// BlockPolicy waits for room in the queue, at most Timeout unless it is zero
+type BlockPolicy struct {
+	Timeout time.Duration
+}
+
+func (b BlockPolicy) RejectedExecution(task Runnable, pool *ThreadPool) error {
+	if pool.offerTimeout(task, b.Timeout) {
+		return nil
+	}
+	if pool.IsShutdown() {
+		return ErrPoolShutdown
+	}
+	return ErrQueueFull
+}
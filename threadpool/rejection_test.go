@@ -0,0 +1,106 @@
+package threadpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockPool returns a pool whose single worker is busy until release is closed and whose queue is full
+func blockPool(t *testing.T, options ...Option) (*ThreadPool, chan struct{}, *Future[int]) {
+	release := make(chan struct{})
+	pool := NewThreadPool(1, 1, options...)
+
+	started := make(chan struct{})
+	_ = pool.Execute(RunnableFunc(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	queued, err := Submit[int](pool, CallableFunc[int](func() (int, error) { return 1, nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the dispatcher holds the queued task while it waits for the worker, the filler takes its place
+	for pool.jobQueue.Len() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if !pool.offer(RunnableFunc(func() {})) {
+		t.Fatal("expected room for the filler task")
+	}
+	return pool, release, queued
+}
+
+func TestAbortPolicy(t *testing.T) {
+	pool, release, _ := blockPool(t)
+	defer close(release)
+
+	if err := pool.Execute(RunnableFunc(func() {})); err != ErrQueueFull {
+		t.Fatalf("expected the queue to be full, got %v", err)
+	}
+}
+
+func TestCallerRunsPolicy(t *testing.T) {
+	pool, release, _ := blockPool(t, WithRejectedExecutionHandler(CallerRunsPolicy{}))
+	defer close(release)
+
+	ran := false
+	if err := pool.Execute(RunnableFunc(func() { ran = true })); err != nil || !ran {
+		t.Fatalf("expected the task to run on the caller, got %v", err)
+	}
+}
+
+func TestDiscardOldestPolicy(t *testing.T) {
+	pool, release, _ := blockPool(t, WithRejectedExecutionHandler(DiscardOldestPolicy{}))
+	defer close(release)
+
+	future, err := Submit[int](pool, CallableFunc[int](func() (int, error) { return 2, nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	future2, _ := Submit[int](pool, CallableFunc[int](func() (int, error) { return 3, nil }))
+	if _, err := future.GetTimeout(time.Second); err != ErrTaskDiscarded {
+		t.Fatalf("expected the oldest task to be discarded, got %v", err)
+	}
+	if future2.IsDone() {
+		t.Fatal("expected the newest task to stay queued")
+	}
+}
+
+func TestDiscardOldestPolicyWithoutQueue(t *testing.T) {
+	pool := NewThreadPool(1, 0, WithRejectedExecutionHandler(DiscardOldestPolicy{}))
+	release := make(chan struct{})
+	// a queue of size zero only takes a task while the dispatcher is waiting for one
+	waitFor(t, func() bool { return pool.offer(RunnableFunc(func() { <-release })) })
+	waitFor(t, func() bool { return pool.Stats().ActiveWorkers == 1 })
+	// the dispatcher takes this one and holds it until the worker is free
+	waitFor(t, func() bool { return pool.offer(RunnableFunc(func() {})) })
+	waitFor(t, func() bool { return pool.jobQueue.Len() == 0 })
+
+	// nothing is queued which could be dropped, the policy gives up instead of spinning
+	if err := pool.Execute(RunnableFunc(func() {})); err != ErrQueueFull {
+		t.Fatalf("expected the task to be rejected, got %v", err)
+	}
+
+	pool.Shutdown()
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockPolicy(t *testing.T) {
+	pool, release, queued := blockPool(t, WithRejectedExecutionHandler(BlockPolicy{Timeout: 10 * time.Millisecond}))
+
+	if err := pool.Execute(RunnableFunc(func() {})); err != ErrQueueFull {
+		t.Fatalf("expected the wait to time out, got %v", err)
+	}
+
+	close(release)
+	if _, err := queued.GetTimeout(time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
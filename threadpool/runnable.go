@@ -3,4 +3,11 @@ package threadpool
 // Runnable is interface for the jobs that will be executed by the threadpool
 type Runnable interface {
 	Run()
-}
\ No newline at end of file
+}
+
+// RunnableFunc adapts a function to a Runnable
+type RunnableFunc func()
+
+func (r RunnableFunc) Run() {
+	r()
+}
@@ -0,0 +1,162 @@
+package threadpool
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+)
+
+var ErrPeriod = fmt.Errorf("the period of a repeating task must be positive")
+
+// ScheduledFuture is the future of a delayed or periodic task
+// The future of a periodic task never completes with a result, it fails once the task is cancelled,
+// panics or the pool is shut down
+type ScheduledFuture[T any] struct {
+	*Future[T]
+	pool  *ScheduledThreadPool
+	entry *scheduledTask
+}
+
+// Cancel completes the future with ErrFutureCancelled and removes the task from the schedule
+// A run which has already started keeps running but the task is not repeated
+func (s *ScheduledFuture[T]) Cancel() bool {
+	if !s.Future.Cancel() {
+		return false
+	}
+	s.pool.remove(s.entry)
+	return true
+}
+
+// GetDelay returns how long it takes until the next run, zero once the task is running or done
+func (s *ScheduledFuture[T]) GetDelay() time.Duration {
+	s.pool.mutex.Lock()
+	defer s.pool.mutex.Unlock()
+
+	if s.entry.index < 0 {
+		return 0
+	}
+	if delay := s.entry.deadline - s.pool.now(); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// periodicTask runs the task and puts itself back on the schedule afterwards, so runs never overlap
+type periodicTask struct {
+	pool      *ScheduledThreadPool
+	entry     *scheduledTask
+	task      Runnable
+	period    time.Duration
+	fixedRate bool
+	cron      *cronTiming // nil unless the task follows a cron expression
+	future    *Future[any]
+}
+
+func (p *periodicTask) Run() {
+	if p.future.IsDone() {
+		return
+	}
+	// A panic ends the repetition, the worker still reports it to the PanicHandler
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err := newPanicError(recovered)
+			p.future.complete(nil, err)
+			panic(err)
+		}
+	}()
+
+	if p.cron == nil || p.cron.schedule.misfire != MisfireSkip || !p.cron.late(time.Now()) {
+		p.task.Run()
+	}
+	p.pool.reschedule(p)
+}
+
+// next returns the deadline of the following run, false when there is none
+// A fixed rate task which overran its period skips the runs it missed instead of catching up
+func (p *periodicTask) next(now time.Duration) (time.Duration, bool) {
+	if p.cron != nil {
+		delay, ok := p.cron.next(time.Now())
+		return p.pool.deadline(delay), ok
+	}
+	if !p.fixedRate {
+		return p.pool.deadline(p.period), true
+	}
+
+	deadline := p.entry.deadline + p.period
+	if deadline < now {
+		deadline += (now - deadline + p.period - 1) / p.period * p.period
+	}
+	return p.pool.roundUp(deadline), true
+}
+
+// Schedule runs the callable once the delay has passed
+func Schedule[T any](stf *ScheduledThreadPool, task Callable[T], delay time.Duration) (*ScheduledFuture[T], error) {
+	future := newFuture[T]()
+	entry, err := stf.schedule(callableTask[T]{Task: task, Handle: future}, delay)
+	if err != nil {
+		return nil, err
+	}
+	return &ScheduledFuture[T]{Future: future, pool: stf, entry: entry}, nil
+}
+
+// ScheduleAtFixedRate runs the task after the initial delay and then once every period measured from
+// the start of the previous run. A run which takes longer than the period delays the next one.
+func (stf *ScheduledThreadPool) ScheduleAtFixedRate(task Runnable, initialDelay, period time.Duration) (*ScheduledFuture[any], error) {
+	return stf.schedulePeriodic(task, initialDelay, period, true)
+}
+
+// ScheduleWithFixedDelay runs the task after the initial delay and then again each time the delay
+// has passed after the end of the previous run
+func (stf *ScheduledThreadPool) ScheduleWithFixedDelay(task Runnable, initialDelay, delay time.Duration) (*ScheduledFuture[any], error) {
+	return stf.schedulePeriodic(task, initialDelay, delay, false)
+}
+
+func (stf *ScheduledThreadPool) schedulePeriodic(task Runnable, initialDelay, period time.Duration, fixedRate bool) (*ScheduledFuture[any], error) {
+	if period <= 0 {
+		return nil, ErrPeriod
+	}
+
+	periodic := &periodicTask{pool: stf, task: task, period: period, fixedRate: fixedRate, future: newFuture[any]()}
+	entry, err := stf.schedule(periodic, initialDelay)
+	if err != nil {
+		return nil, err
+	}
+	return &ScheduledFuture[any]{Future: periodic.future, pool: stf, entry: entry}, nil
+}
+
+// reschedule puts the periodic task back on the heap, unless it is done or the pool is shut down
+func (stf *ScheduledThreadPool) reschedule(p *periodicTask) {
+	stf.mutex.Lock()
+	if p.future.IsDone() {
+		stf.mutex.Unlock()
+		return
+	}
+	if stf.IsShutdown() {
+		stf.mutex.Unlock()
+		p.future.complete(nil, ErrPoolShutdown)
+		return
+	}
+
+	deadline, ok := p.next(stf.now())
+	if !ok {
+		stf.mutex.Unlock()
+		p.future.complete(nil, nil)
+		return
+	}
+	p.entry.deadline = deadline
+	heap.Push(&stf.timers, p.entry)
+	if p.entry.index == 0 {
+		stf.wake()
+	}
+	stf.mutex.Unlock()
+}
+
+// remove takes the task off the heap unless it has already left it
+func (stf *ScheduledThreadPool) remove(entry *scheduledTask) {
+	stf.mutex.Lock()
+	defer stf.mutex.Unlock()
+
+	if entry.index >= 0 {
+		heap.Remove(&stf.timers, entry.index)
+	}
+}
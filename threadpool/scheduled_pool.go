@@ -1,89 +1,334 @@
 package threadpool
 
 import (
+	"container/heap"
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const defaultResolution = time.Millisecond
+
+// ScheduledThreadPool runs tasks on its executor once their delay has passed
+// The delayed tasks are kept in a min heap ordered by deadline, a single timer waits for the earliest one
 type ScheduledThreadPool struct {
-	workers     chan chan interface{}
-	tasks       *sync.Map
+	executor    *ThreadPool
 	noOfWorkers int
-	counter     uint64
-	counterLock sync.Mutex
-	closeHandle chan bool
+	// deadlines are rounded up to the resolution so that close timers fire together
+	resolution time.Duration
+	epoch      time.Time
+	// options of the executor running the due tasks
+	executorOptions []Option
+
+	mutex    sync.Mutex
+	timers   timerHeap
+	sequence uint64
+	// signals the dispatcher that the earliest deadline has changed
+	wakeup chan struct{}
+	// cancelled by ShutdownNow, it also ends the wait for room in the executor
+	stopContext context.Context
+	cancelStop  context.CancelFunc
+
+	state int32
+	// closed once the scheduler has handed its last task to the executor
+	dispatchDone chan struct{}
+}
+
+// ScheduledOption configures the scheduled thread pool
+type ScheduledOption func(*ScheduledThreadPool)
+
+// WithResolution sets the granularity of the deadlines, it defaults to a millisecond
+func WithResolution(resolution time.Duration) ScheduledOption {
+	return func(stf *ScheduledThreadPool) {
+		stf.resolution = resolution
+	}
+}
+
+// WithExecutorOptions configures the pool which runs the tasks once they are due, e.g. its PanicHandler
+// Its rejection policy is not used, a due task waits for room in the queue of the executor.
+func WithExecutorOptions(options ...Option) ScheduledOption {
+	return func(stf *ScheduledThreadPool) {
+		stf.executorOptions = append(stf.executorOptions, options...)
+	}
 }
 
-func NewScheduledThreadPool(noOfWorkers int) *ScheduledThreadPool {
+func NewScheduledThreadPool(noOfWorkers int, options ...ScheduledOption) *ScheduledThreadPool {
 	pool := &ScheduledThreadPool{}
 	pool.noOfWorkers = noOfWorkers
-	pool.workers = make(chan chan interface{}, noOfWorkers)
-	pool.tasks = new(sync.Map)
-	pool.closeHandle = make(chan bool)
+	pool.resolution = defaultResolution
+	for _, option := range options {
+		option(pool)
+	}
+	if pool.resolution <= 0 {
+		pool.resolution = defaultResolution
+	}
+	pool.executor = NewThreadPool(noOfWorkers, int64(noOfWorkers), pool.executorOptions...)
+	pool.epoch = time.Now()
+	pool.wakeup = make(chan struct{}, 1)
+	pool.stopContext, pool.cancelStop = context.WithCancel(context.Background())
+	pool.dispatchDone = make(chan struct{})
 	pool.createPool()
 	return pool
 }
 
 func (stf *ScheduledThreadPool) createPool() {
-	for i := 0; i < stf.noOfWorkers; i++ {
-		worker := NewWorker(stf.workers, stf.closeHandle)
-		worker.Start()
+	stf.executor.PrestartAllCoreThreads()
+	go stf.dispatch()
+}
+
+// scheduledTask is an entry of the timer heap
+type scheduledTask struct {
+	task     Runnable
+	deadline time.Duration // since the epoch of the pool
+	sequence uint64        // keeps the tasks with the same deadline in submission order
+	index    int           // position in the heap, -1 once the task has left it
+	periodic *periodicTask // nil for the tasks which run once
+}
+
+type timerHeap []*scheduledTask
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].deadline != h[j].deadline {
+		return h[i].deadline < h[j].deadline
+	}
+	return h[i].sequence < h[j].sequence
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	item := x.(*scheduledTask)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil // let go of the fired task
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+// now returns the monotonic time since the epoch of the pool
+func (stf *ScheduledThreadPool) now() time.Duration {
+	return time.Since(stf.epoch)
+}
+
+// deadline rounds the moment the delay passes up to the resolution
+func (stf *ScheduledThreadPool) deadline(delay time.Duration) time.Duration {
+	if delay < 0 {
+		delay = 0
 	}
+	return stf.roundUp(stf.now() + delay)
+}
 
-	go stf.dispatch()
+func (stf *ScheduledThreadPool) roundUp(deadline time.Duration) time.Duration {
+	if rest := deadline % stf.resolution; rest != 0 {
+		deadline += stf.resolution - rest
+	}
+	return deadline
 }
 
 func (stf *ScheduledThreadPool) dispatch() {
+	defer close(stf.dispatchDone)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
 	for {
+		due, wait, drained := stf.poll()
+
+		// For each task , get a worker from the threadpool and run the task
+		for i, item := range due {
+			if !stf.handOff(item.task) {
+				stf.restore(due[i:])
+				return
+			}
+		}
+
+		// After Shutdown the scheduler keeps going until the delayed tasks have been handed off
+		if drained {
+			stf.executor.Shutdown()
+			return
+		}
+
+		var expired <-chan time.Time
+		if wait >= 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			expired = timer.C
+		}
+
 		select {
-		case <-stf.closeHandle:
+		case <-expired:
+		case <-stf.wakeup:
+		case <-stf.stopContext.Done():
 			//Stop the scheduler
 			return
-		default:
-			go stf.intervalRunner()
-			time.Sleep(time.Second * 1)
 		}
 	}
 }
 
-func (stf *ScheduledThreadPool) intervalRunner() {
+// handOff queues the due task on the executor. When its queue is full the scheduler waits for room,
+// the tasks which become due in the meantime stay on the heap so they are handed off in deadline order.
+// It returns false when ShutdownNow ends the wait.
+func (stf *ScheduledThreadPool) handOff(job Runnable) bool {
+	return stf.executor.offer(job) || stf.executor.put(stf.stopContext, job)
+}
 
-	stf.updateCounter()
+// restore puts due tasks which were not handed off back on the heap for ShutdownNow to return
+func (stf *ScheduledThreadPool) restore(due []*scheduledTask) {
+	stf.mutex.Lock()
+	defer stf.mutex.Unlock()
+	for _, item := range due {
+		heap.Push(&stf.timers, item)
+	}
+}
 
-	currentTasksToRun, ok := stf.tasks.Load(stf.counter)
+// poll takes the tasks which are due off the heap and returns how long to wait for the next one,
+// a negative wait means that no task is pending
+func (stf *ScheduledThreadPool) poll() (due []*scheduledTask, wait time.Duration, drained bool) {
+	stf.mutex.Lock()
+	defer stf.mutex.Unlock()
 
-	if ok {
-		currentTasksSet := currentTasksToRun.(*Set)
+	now := stf.now()
+	for len(stf.timers) > 0 && stf.timers[0].deadline <= now {
+		due = append(due, heap.Pop(&stf.timers).(*scheduledTask))
+	}
 
-		// For each task , get a worker from the threadpool and run the task
-		for _, val := range currentTasksSet.GetAll() {
-			go func(job interface{}) {
-				worker := <-stf.workers
-				worker <- job
-			}(val)
-		}
+	if len(stf.timers) == 0 {
+		return due, -1, atomic.LoadInt32(&stf.state) == stateShutdown
+	}
+	return due, stf.timers[0].deadline - now, false
+}
+
+// schedule puts the task on the heap and wakes the dispatcher when it is the earliest one
+func (stf *ScheduledThreadPool) schedule(task Runnable, delay time.Duration) (*scheduledTask, error) {
+	stf.mutex.Lock()
+	defer stf.mutex.Unlock()
+
+	if atomic.LoadInt32(&stf.state) != stateRunning {
+		return nil, ErrPoolShutdown
+	}
+
+	stf.sequence++
+	item := &scheduledTask{task: task, deadline: stf.deadline(delay), sequence: stf.sequence}
+	if periodic, ok := task.(*periodicTask); ok {
+		periodic.entry, item.periodic = item, periodic
+	}
+	heap.Push(&stf.timers, item)
+	if item.index == 0 {
+		stf.wake()
+	}
+	return item, nil
+}
+
+func (stf *ScheduledThreadPool) wake() {
+	select {
+	case stf.wakeup <- struct{}{}:
+	default:
 	}
 }
 
-func (stf *ScheduledThreadPool) updateCounter() {
-	stf.counterLock.Lock()
-	defer stf.counterLock.Unlock()
-	stf.counter++
+// ScheduleOnce runs the task once the delay has passed, it fails once the pool is shut down
+func (stf *ScheduledThreadPool) ScheduleOnce(task Runnable, delay time.Duration) error {
+	_, err := stf.schedule(task, delay)
+	return err
 }
 
-func (stf *ScheduledThreadPool) ScheduleOnce(task Runnable, delay time.Duration) {
-	scheduleTime := stf.counter + uint64(delay.Seconds())
-	existingTasks, ok := stf.tasks.Load(scheduleTime)
+// Pending returns how many tasks are waiting for their delay to pass
+func (stf *ScheduledThreadPool) Pending() int {
+	stf.mutex.Lock()
+	defer stf.mutex.Unlock()
+	return len(stf.timers)
+}
+
+// Shutdown stops accepting tasks, the delayed ones still run once their delay has passed
+// Periodic tasks are not repeated anymore, their futures fail with ErrPoolShutdown
+func (stf *ScheduledThreadPool) Shutdown() {
+	stf.mutex.Lock()
+	atomic.CompareAndSwapInt32(&stf.state, stateRunning, stateShutdown)
+
+	var stopped []*periodicTask
+	remaining := stf.timers[:0]
+	for _, item := range stf.timers {
+		if item.periodic != nil {
+			item.index = -1
+			stopped = append(stopped, item.periodic)
+			continue
+		}
+		item.index = len(remaining)
+		remaining = append(remaining, item)
+	}
+	for i := len(remaining); i < len(stf.timers); i++ {
+		stf.timers[i] = nil
+	}
+	stf.timers = remaining
+	heap.Init(&stf.timers)
+
+	stf.wake()
+	stf.mutex.Unlock()
+
+	for _, periodic := range stopped {
+		periodic.future.complete(nil, ErrPoolShutdown)
+	}
+}
 
-	// Create new set if no tasks are already there
-	if !ok {
-		existingTasks = NewSet()
-		stf.tasks.Store(scheduleTime, existingTasks)
+// ShutdownNow stops the scheduler and returns the delayed tasks together with the queued ones
+func (stf *ScheduledThreadPool) ShutdownNow() []Runnable {
+	stf.mutex.Lock()
+	atomic.StoreInt32(&stf.state, stateStop)
+	stf.mutex.Unlock()
+
+	stf.cancelStop()
+	<-stf.dispatchDone
+
+	stf.mutex.Lock()
+	var pending []Runnable
+	for len(stf.timers) > 0 {
+		pending = append(pending, heap.Pop(&stf.timers).(*scheduledTask).task)
+	}
+	stf.mutex.Unlock()
+
+	return append(pending, stf.executor.ShutdownNow()...)
+}
+
+// AwaitTermination blocks until every scheduled task has completed after a shutdown, or until the context is done
+func (stf *ScheduledThreadPool) AwaitTermination(ctx context.Context) error {
+	select {
+	case <-stf.dispatchDone:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	// Add task
-	existingTasks.(*Set).Add(task)
+	return stf.executor.AwaitTermination(ctx)
+}
+
+// IsShutdown returns true once Shutdown or ShutdownNow has been called
+func (stf *ScheduledThreadPool) IsShutdown() bool {
+	return atomic.LoadInt32(&stf.state) != stateRunning
+}
+
+// IsTerminated returns true once every scheduled task has completed after a shutdown
+func (stf *ScheduledThreadPool) IsTerminated() bool {
+	return stf.executor.IsTerminated()
 }
 
+// Close shuts the pool down, the delayed tasks still run
 func (stf *ScheduledThreadPool) Close() {
-	close(stf.closeHandle)
+	stf.Shutdown()
 }
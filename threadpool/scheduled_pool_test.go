@@ -0,0 +1,138 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduleOnceOrdersByDeadline(t *testing.T) {
+	pool := NewScheduledThreadPool(1)
+
+	var mutex sync.Mutex
+	var order []int
+	start := time.Now()
+	for _, delay := range []int{30, 10, 20} {
+		delay := delay
+		err := pool.ScheduleOnce(RunnableFunc(func() {
+			if elapsed := time.Since(start); elapsed < time.Duration(delay)*time.Millisecond {
+				t.Errorf("task %d ran after %v", delay, elapsed)
+			}
+			mutex.Lock()
+			order = append(order, delay)
+			mutex.Unlock()
+		}), time.Duration(delay)*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool.Shutdown()
+	if err := pool.ScheduleOnce(RunnableFunc(func() {}), 0); err != ErrPoolShutdown {
+		t.Fatalf("expected the pool to be shut down, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(order) != 3 || order[0] != 10 || order[1] != 20 || order[2] != 30 {
+		t.Fatalf("expected the tasks in deadline order, got %v", order)
+	}
+	if pool.Pending() != 0 {
+		t.Fatal("expected the fired tasks to leave the heap")
+	}
+}
+
+func TestScheduledShutdownNowReturnsDelayedTasks(t *testing.T) {
+	pool := NewScheduledThreadPool(1, WithResolution(10*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		_ = pool.ScheduleOnce(RunnableFunc(func() { t.Error("expected the delayed task not to run") }), time.Hour)
+	}
+
+	if pending := pool.ShutdownNow(); len(pending) != 3 {
+		t.Fatalf("expected 3 delayed tasks back, got %d", len(pending))
+	}
+}
+
+func TestScheduledBacklogKeepsDeadlineOrder(t *testing.T) {
+	pool := NewScheduledThreadPool(1, WithResolution(100*time.Microsecond))
+
+	release := make(chan struct{})
+	_ = pool.ScheduleOnce(RunnableFunc(func() { <-release }), 0)
+
+	// the tasks become due while the only worker is busy, far more than the executor queue holds
+	var order []int
+	const count = 50
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		i := i
+		deadline := start.Add(time.Duration(count-i) * time.Millisecond)
+		_ = pool.ScheduleOnce(RunnableFunc(func() { order = append(order, i) }), time.Until(deadline))
+	}
+	goroutines := runtime.NumGoroutine()
+	time.Sleep(time.Until(start.Add((count + 10) * time.Millisecond)))
+	if grown := runtime.NumGoroutine() - goroutines; grown > 2 {
+		t.Errorf("expected the due tasks to wait without goroutines, %d were started", grown)
+	}
+	close(release)
+
+	pool.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range order {
+		if value != count-1-i {
+			t.Fatalf("expected the tasks in deadline order, got %v", order)
+		}
+	}
+}
+
+func TestScheduleCallable(t *testing.T) {
+	pool := NewScheduledThreadPool(1)
+	defer pool.ShutdownNow()
+
+	future, err := Schedule[int](pool, CallableFunc[int](func() (int, error) { return 42, nil }), 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay := future.GetDelay(); delay <= 0 || delay > 21*time.Millisecond {
+		t.Fatalf("expected a delay of at most 20ms and the resolution, got %v", delay)
+	}
+	if value, err := future.GetTimeout(time.Second); value != 42 || err != nil {
+		t.Fatalf("expected 42, got %d %v", value, err)
+	}
+}
+
+func TestScheduleAtFixedRate(t *testing.T) {
+	pool := NewScheduledThreadPool(2, WithExecutorOptions(WithPanicHandler(PanicHandlerFunc(func(Runnable, *PanicError) {}))))
+	defer pool.ShutdownNow()
+
+	var runs int32
+	future, err := pool.ScheduleAtFixedRate(RunnableFunc(func() { atomic.AddInt32(&runs, 1) }), 0, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&runs) >= 3 })
+
+	if !future.Cancel() || pool.Pending() != 0 {
+		t.Fatal("expected the cancelled task to leave the schedule")
+	}
+	stopped := atomic.LoadInt32(&runs)
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&runs) > stopped+1 {
+		t.Fatal("expected the cancelled task not to repeat")
+	}
+
+	panicking, _ := pool.ScheduleWithFixedDelay(RunnableFunc(func() { panic("boom") }), 0, time.Millisecond)
+	if _, err := panicking.GetTimeout(time.Second); !errors.Is(err, ErrTaskPanicked) {
+		t.Fatalf("expected the panic to end the repetition, got %v", err)
+	}
+}
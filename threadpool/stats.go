@@ -0,0 +1,106 @@
+package threadpool
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyHistogram counts durations in buckets of powers of two nanoseconds, bucket i holds the
+// durations below 2^i ns which do not fit into a lower bucket. The last bucket holds everything longer.
+type LatencyHistogram [48]uint64
+
+func (h LatencyHistogram) Count() uint64 {
+	var count uint64
+	for _, item := range h {
+		count += item
+	}
+	return count
+}
+
+// Percentile returns the upper bound of the bucket which holds the percentile, e.g. 0.99
+func (h LatencyHistogram) Percentile(percentile float64) time.Duration {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(percentile * float64(count)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, item := range h {
+		if seen += item; seen >= rank {
+			return time.Duration(1) << uint(i)
+		}
+	}
+	return time.Duration(1) << uint(len(h)-1)
+}
+
+// Stats is a snapshot of the pool
+type Stats struct {
+	// workers which are running a task
+	ActiveWorkers	int
+	PoolSize	int
+	QueueDepth	int
+	// tasks which have run on a worker, including the ones which panicked
+	Completed	uint64
+	// tasks which found the queue full and were handed to the rejection policy
+	Rejected	uint64
+	// from queueing a task until a worker starts it
+	QueueWait	LatencyHistogram
+	// from starting a task until it returns
+	Execution	LatencyHistogram
+}
+
+type poolMetrics struct {
+	active		int32
+	completed	uint64
+	rejected	uint64
+	queueWait	LatencyHistogram
+	execution	LatencyHistogram
+}
+
+func (h *LatencyHistogram) record(duration time.Duration) {
+	bucket := 0
+	for value := duration; value > 0 && bucket < len(h)-1; value >>= 1 {
+		bucket++
+	}
+	atomic.AddUint64(&h[bucket], 1)
+}
+
+func (h *LatencyHistogram) snapshot() LatencyHistogram {
+	var copied LatencyHistogram
+	for i := range h {
+		copied[i] = atomic.LoadUint64(&h[i])
+	}
+	return copied
+}
+
+// queuedTask remembers when the task was queued to measure how long it waited for a worker
+type queuedTask struct {
+	task		Runnable
+	enqueued	time.Time
+}
+
+func unwrapTasks(queued []queuedTask) []Runnable {
+	tasks := make([]Runnable, 0, len(queued))
+	for _, item := range queued {
+		tasks = append(tasks, item.task)
+	}
+	return tasks
+}
+
+// Stats takes a snapshot of the workers, the queue and the counters of the pool
+func (t *ThreadPool) Stats() Stats {
+	return Stats{
+		ActiveWorkers: int(atomic.LoadInt32(&t.metrics.active)),
+		PoolSize:      t.PoolSize(),
+		QueueDepth:    t.jobQueue.Len(),
+		Completed:     atomic.LoadUint64(&t.metrics.completed),
+		Rejected:      atomic.LoadUint64(&t.metrics.rejected),
+		QueueWait:     t.metrics.queueWait.snapshot(),
+		Execution:     t.metrics.execution.snapshot(),
+	}
+}
@@ -1,85 +1,569 @@
 package threadpool
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"garry.org/data_structure/priorityqueue"
+)
 
 var (
-	ErrQueueFull = fmt.Errorf("queue is full, not able add the task")
+	ErrQueueFull    = fmt.Errorf("queue is full, not able add the task")
+	ErrPoolSize     = fmt.Errorf("the core pool size must not exceed the maximum pool size of at least 1")
+	ErrPoolShutdown = fmt.Errorf("the pool has been shut down, not able add the task")
+)
+
+const defaultKeepAliveTime = time.Minute
+
+// The lifecycle of a pool only moves forward
+const (
+	stateRunning int32 = iota
+	// stateShutdown accepts no tasks but still runs the queued ones
+	stateShutdown
+	// stateStop accepts no tasks and hands the queued ones back
+	stateStop
+	// stateTerminated has no workers left
+	stateTerminated
 )
 
 type ThreadPool struct {
 	queueSize	int64
-	// number of workers
-	noOfWorkers	int
 
-	jobQueue	chan interface{}
-	workerPool	chan chan interface{}
+	// workers are started on demand up to the maximum pool size, the ones above
+	// the core pool size retire after being idle for the keep alive time
+	mutex		sync.Mutex
+	corePoolSize	int
+	maxPoolSize	int
+	// nanoseconds, read atomically by the idle workers
+	keepAliveTime	int64
+	poolSize	int
+	idleWorkers	[]*Worker
+	workerIdle	*sync.Cond
+	// set once the workers have to exit instead of becoming idle
+	stopping	bool
+	workers		sync.WaitGroup
+
+	// the pending tasks, the one with the highest priority is dispatched first
+	jobQueue	*priorityqueue.BlockingPriorityQueue[queuedTask]
+	// every interval of waiting raises the priority of a pending task by one, zero disables aging
+	agingInterval	time.Duration
+	epoch		time.Time
 	// Channel used to stop all the workers
 	closeHandle	chan bool
+
+	state		int32
+	// submitters which have seen the running state but may not have queued their task yet
+	submitting	int32
+	// cancelled once the pool is shut down and the last submitter has left
+	submittersContext	context.Context
+	submittersGone		context.CancelFunc
+	// done once the pool stops accepting tasks
+	shutdownContext	context.Context
+	cancelShutdown	context.CancelFunc
+	dispatcherDone	chan struct{}
+	terminated	chan struct{}
+	// the job the dispatcher was holding when the pool was stopped
+	leftover	*queuedTask
+	// decides what happens to the tasks which do not fit into the queue
+	rejectedHandler	RejectedExecutionHandler
+	panicHandler	PanicHandler
+
+	beforeExecute	func(task Runnable)
+	afterExecute	func(task Runnable, err error)
+	onTerminated	func()
+	metrics		poolMetrics
 }
 
-// NewThreadPool creates thread threadpool
-func NewThreadPool(noOfWorkers int, queueSize int64) *ThreadPool {
-	threadPool := &ThreadPool{queueSize: queueSize, noOfWorkers: noOfWorkers}
-	threadPool.jobQueue = make(chan interface{}, queueSize)
-	threadPool.workerPool = make(chan chan interface{}, noOfWorkers)
-	threadPool.closeHandle = make(chan bool)
-	threadPool.createPool()
-	return threadPool
+// Option configures the thread pool
+type Option func(*ThreadPool)
+
+// WithRejectedExecutionHandler sets the policy for tasks submitted while the queue is full
+func WithRejectedExecutionHandler(handler RejectedExecutionHandler) Option {
+	return func(t *ThreadPool) {
+		t.rejectedHandler = handler
+	}
+}
+
+// WithPanicHandler sets the hook which is told about tasks that panicked, they are logged by default
+func WithPanicHandler(handler PanicHandler) Option {
+	return func(t *ThreadPool) {
+		t.panicHandler = handler
+	}
+}
+
+// WithBeforeExecute sets a hook which runs on the worker right before each task
+func WithBeforeExecute(hook func(task Runnable)) Option {
+	return func(t *ThreadPool) {
+		t.beforeExecute = hook
+	}
+}
+
+// WithAfterExecute sets a hook which runs on the worker right after each task, err is the
+// PanicError of a task which panicked
+func WithAfterExecute(hook func(task Runnable, err error)) Option {
+	return func(t *ThreadPool) {
+		t.afterExecute = hook
+	}
+}
+
+// WithTerminated sets a hook which runs once the pool has terminated after a shutdown
+func WithTerminated(hook func()) Option {
+	return func(t *ThreadPool) {
+		t.onTerminated = hook
+	}
+}
+
+// WithAging lets pending tasks gain one priority level for every interval they wait, so that tasks
+// of a low priority eventually run while tasks of a higher priority keep arriving
+func WithAging(interval time.Duration) Option {
+	return func(t *ThreadPool) {
+		t.agingInterval = interval
+	}
+}
+
+// WithMaximumPoolSize lets the pool grow above its core size while no worker is idle
+func WithMaximumPoolSize(maxPoolSize int) Option {
+	return func(t *ThreadPool) {
+		t.maxPoolSize = maxPoolSize
+	}
 }
 
-func (t *ThreadPool) createPool() {
-	for i:=0; i<t.noOfWorkers; i++ {
-		worker := NewWorker(t.workerPool, t.closeHandle)
-		worker.Start()
+// WithKeepAliveTime sets how long a worker above the core pool size may be idle before it retires
+func WithKeepAliveTime(keepAliveTime time.Duration) Option {
+	return func(t *ThreadPool) {
+		t.keepAliveTime = int64(keepAliveTime)
+	}
+}
+
+// NewThreadPool creates thread threadpool
+// noOfWorkers is the core pool size, the workers are started when the first jobs arrive
+func NewThreadPool(noOfWorkers int, queueSize int64, options ...Option) *ThreadPool {
+	threadPool := &ThreadPool{
+		queueSize:       queueSize,
+		corePoolSize:    noOfWorkers,
+		maxPoolSize:     noOfWorkers,
+		keepAliveTime:   int64(defaultKeepAliveTime),
+		rejectedHandler: AbortPolicy{},
+		panicHandler:    logPanicHandler{},
 	}
-	go t.dispatch()
+	for _, option := range options {
+		option(threadPool)
+	}
+	if threadPool.maxPoolSize < threadPool.corePoolSize {
+		threadPool.maxPoolSize = threadPool.corePoolSize
+	}
+	if threadPool.maxPoolSize < 1 {
+		threadPool.maxPoolSize = 1
+	}
+	threadPool.workerIdle = sync.NewCond(&threadPool.mutex)
+	threadPool.jobQueue = priorityqueue.NewBlockingPriorityQueue[queuedTask](int(queueSize))
+	threadPool.epoch = time.Now()
+	threadPool.closeHandle = make(chan bool)
+	threadPool.shutdownContext, threadPool.cancelShutdown = context.WithCancel(context.Background())
+	threadPool.submittersContext, threadPool.submittersGone = context.WithCancel(context.Background())
+	threadPool.dispatcherDone = make(chan struct{})
+	threadPool.terminated = make(chan struct{})
+	go threadPool.dispatch()
+	return threadPool
 }
 
 // dispatch listens to the jobqueue and handles the jobs to the workers
 func (t *ThreadPool) dispatch() {
+	defer t.terminate()
+	defer close(t.dispatcherDone)
+
 	for {
-		select {
-		case job := <-t.jobQueue:
-			func(job interface{}) {
-				// find a worker for the job
-				jobChannel := <-t.workerPool
-				// submit job to the worker
-				jobChannel <- job
-			}(job)
-
-		case <- t.closeHandle:
+		job, err := t.jobQueue.Take(t.shutdownContext)
+		if err != nil {
+			t.drain()
+			return
+		}
+		if !t.handOff(job) {
+			return
+		}
+	}
+}
+
+// handOff finds a worker for the job, it returns false once the pool has been stopped
+func (t *ThreadPool) handOff(job queuedTask) bool {
+	worker := t.acquireWorker()
+	if worker == nil {
+		t.leftover = &job
+		return false
+	}
+	// submit job to the worker
+	worker.jobChannel <- job
+	return true
+}
+
+// drain keeps handing off the queued jobs after Shutdown until no submitter can queue one anymore
+func (t *ThreadPool) drain() {
+	for atomic.LoadInt32(&t.state) == stateShutdown {
+		// the queued jobs are taken even once the submitters are gone
+		job, err := t.jobQueue.Take(t.submittersContext)
+		if err != nil || !t.handOff(job) {
 			return
 		}
 	}
 }
 
-func (t *ThreadPool) submitTask (task interface{}) error {
-	if len(t.jobQueue) == int(t.queueSize) {
-		return ErrQueueFull
+// enter registers a submitter, it has to check the state of the pool afterwards
+func (t *ThreadPool) enter() {
+	atomic.AddInt32(&t.submitting, 1)
+}
+
+// leave lets drain and ShutdownNow go on once the pool is shut down and the last submitter has left.
+// A submitter which saw the running state entered before the shutdown, so either the shutdown
+// sees it in submitting or it sees the shutdown here.
+func (t *ThreadPool) leave() {
+	if atomic.AddInt32(&t.submitting, -1) == 0 && atomic.LoadInt32(&t.state) != stateRunning {
+		t.submittersGone()
+	}
+}
+
+// stopSubmitters is called once the state has left running
+func (t *ThreadPool) stopSubmitters() {
+	if atomic.LoadInt32(&t.submitting) == 0 {
+		t.submittersGone()
 	}
-	t.jobQueue <- task
+}
+
+// terminate lets the workers exit once they are done and marks the pool as terminated
+func (t *ThreadPool) terminate() {
+	t.mutex.Lock()
+	t.stopping = true
+	t.mutex.Unlock()
+
+	close(t.closeHandle)
+	t.workers.Wait()
+
+	if t.onTerminated != nil {
+		t.onTerminated()
+	}
+	atomic.StoreInt32(&t.state, stateTerminated)
+	close(t.terminated)
+}
+
+// acquireWorker takes the idle worker which was released last, starts a new one while the pool
+// is below its maximum size or waits for one to become idle. It returns nil once the pool is closed.
+func (t *ThreadPool) acquireWorker() *Worker {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for len(t.idleWorkers) == 0 {
+		if atomic.LoadInt32(&t.state) >= stateStop {
+			return nil
+		}
+		if t.poolSize < t.maxPoolSize {
+			t.addWorker()
+		}
+		t.workerIdle.Wait()
+	}
+
+	last := len(t.idleWorkers) - 1
+	worker := t.idleWorkers[last]
+	t.idleWorkers[last] = nil
+	t.idleWorkers = t.idleWorkers[:last]
+	return worker
+}
+
+// addWorker starts a worker which registers itself as idle, the caller holds the mutex
+func (t *ThreadPool) addWorker() {
+	t.poolSize++
+	t.workers.Add(1)
+	NewWorker(t).Start()
+}
+
+// release puts the worker back to the idle workers, it returns false when the worker
+// has to retire because the maximum pool size has been lowered or the pool is stopping
+func (t *ThreadPool) release(w *Worker) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stopping || t.poolSize > t.maxPoolSize {
+		t.poolSize--
+		return false
+	}
+	t.idleWorkers = append(t.idleWorkers, w)
+	t.workerIdle.Signal()
+	return true
+}
+
+// retire removes the idle worker from the pool when the pool is above its core size,
+// it returns false when the worker has to stay or when the dispatcher has already picked it
+func (t *ThreadPool) retire(w *Worker) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.poolSize <= t.corePoolSize || !t.removeIdle(w) {
+		return false
+	}
+	t.poolSize--
+	return true
+}
+
+// replace starts a new worker for the one which is lost because its task panicked
+func (t *ThreadPool) replace(w *Worker) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.poolSize--
+	if !t.stopping && t.poolSize < t.maxPoolSize {
+		t.addWorker()
+	}
+}
+
+// exit removes the worker which stops because the pool has terminated
+func (t *ThreadPool) exit(w *Worker) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.removeIdle(w) {
+		t.poolSize--
+	}
+}
+
+// removeIdle takes the worker out of the idle workers, the caller holds the mutex
+func (t *ThreadPool) removeIdle(w *Worker) bool {
+	for i, item := range t.idleWorkers {
+		if item == w {
+			copy(t.idleWorkers[i:], t.idleWorkers[i+1:])
+			t.idleWorkers[len(t.idleWorkers)-1] = nil
+			t.idleWorkers = t.idleWorkers[:len(t.idleWorkers)-1]
+			return true
+		}
+	}
+	return false
+}
+
+// PrestartAllCoreThreads starts the missing core workers instead of waiting for jobs, it returns how many were started
+func (t *ThreadPool) PrestartAllCoreThreads() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	started := 0
+	for ; t.poolSize < t.corePoolSize && !t.stopping && atomic.LoadInt32(&t.state) == stateRunning; started++ {
+		t.addWorker()
+	}
+	return started
+}
+
+// SetCorePoolSize changes the number of workers which never retire
+// Idle workers above the new core size retire after the keep alive time
+func (t *ThreadPool) SetCorePoolSize(corePoolSize int) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if corePoolSize < 0 || corePoolSize > t.maxPoolSize {
+		return ErrPoolSize
+	}
+	t.corePoolSize = corePoolSize
 	return nil
 }
 
+// SetMaximumPoolSize changes the number of workers the pool may grow to
+// Idle workers above the new maximum size retire right away, busy ones once they are done
+func (t *ThreadPool) SetMaximumPoolSize(maxPoolSize int) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if maxPoolSize < 1 || maxPoolSize < t.corePoolSize {
+		return ErrPoolSize
+	}
+	t.maxPoolSize = maxPoolSize
+	t.trimIdle()
+	return nil
+}
+
+// SetKeepAliveTime changes how long workers above the core pool size may be idle
+func (t *ThreadPool) SetKeepAliveTime(keepAliveTime time.Duration) {
+	atomic.StoreInt64(&t.keepAliveTime, int64(keepAliveTime))
+}
+
+// trimIdle retires idle workers while the pool is above its maximum size, the caller holds the mutex
+func (t *ThreadPool) trimIdle() {
+	for t.poolSize > t.maxPoolSize && len(t.idleWorkers) > 0 {
+		worker := t.idleWorkers[0]
+		t.removeIdle(worker)
+		close(worker.jobChannel)
+		t.poolSize--
+	}
+}
+
+func (t *ThreadPool) CorePoolSize() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.corePoolSize
+}
+
+func (t *ThreadPool) MaximumPoolSize() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.maxPoolSize
+}
+
+func (t *ThreadPool) KeepAliveTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.keepAliveTime))
+}
+
+// room estimates how many more tasks the pool takes without handing them to the rejection policy
+func (t *ThreadPool) room() int {
+	return t.MaximumPoolSize() - int(atomic.LoadInt32(&t.metrics.active)) + int(t.queueSize) - t.jobQueue.Len()
+}
+
+// PoolSize returns the number of workers, idle or busy
+func (t *ThreadPool) PoolSize() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.poolSize
+}
+
+func (t *ThreadPool) submitTask (task Runnable) error {
+	t.enter()
+	defer t.leave()
+
+	if atomic.LoadInt32(&t.state) != stateRunning {
+		return ErrPoolShutdown
+	}
+	if t.offer(task) {
+		return nil
+	}
+	atomic.AddUint64(&t.metrics.rejected, 1)
+	return t.rejectedHandler.RejectedExecution(task, t)
+}
+
+// requeue queues a task which the pool has accepted before, e.g. the next turn of a lane
+// Unlike submitTask it never calls the rejection policy, the caller keeps the task when it fails
+func (t *ThreadPool) requeue(task Runnable) bool {
+	t.enter()
+	defer t.leave()
+
+	return atomic.LoadInt32(&t.state) == stateRunning && t.offer(task)
+}
+
+// offer queues the task unless the queue is full, checking and queueing is a single step
+func (t *ThreadPool) offer(task Runnable) bool {
+	return t.jobQueue.Offer(queuedTask{task: task, enqueued: time.Now()}, t.priorityKey(task))
+}
+
+// offerTimeout waits at most the timeout for room in the queue, forever if the timeout is zero
+// It gives up once the pool is shut down
+func (t *ThreadPool) offerTimeout(task Runnable, timeout time.Duration) bool {
+	ctx := t.shutdownContext
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return t.put(ctx, task)
+}
+
+// put waits for room in the queue until the context is done
+func (t *ThreadPool) put(ctx context.Context, task Runnable) bool {
+	return t.jobQueue.Put(ctx, queuedTask{task: task, enqueued: time.Now()}, t.priorityKey(task)) == nil
+}
+
+// pollNext removes the task which would be dispatched next, nil if the queue is empty
+func (t *ThreadPool) pollNext() Runnable {
+	queued, _ := t.jobQueue.Poll()
+	return queued.task
+}
+
+// priorityKey orders the queue, with aging a task which has waited for an interval ties with a
+// task of the next higher priority that is queued now. The key does not change while it is queued.
+func (t *ThreadPool) priorityKey(task Runnable) int64 {
+	priority := int64(priorityOf(task))
+	if t.agingInterval <= 0 {
+		return priority
+	}
+	return priority*int64(t.agingInterval) - int64(time.Since(t.epoch))
+}
+
 // Execute submits the job to available worker
 func (t *ThreadPool) Execute(task Runnable) error {
 	return t.submitTask(task)
 }
 
-func (t *ThreadPool) ExecuteFuture(task Callable) (*Future, error) {
+// ExecuteFuture submits the callable and returns the future of its response
+func (t *ThreadPool) ExecuteFuture(task Callable[any]) (*Future[any], error) {
+	return Submit[any](t, task)
+}
+
+// Submit is the typed version of ExecuteFuture
+func Submit[T any](t *ThreadPool, task Callable[T]) (*Future[T], error) {
 	// Create future and task
-	handle := &Future{response: make(chan interface{})}
-	futureTask := callableTask{Task: task, Handle: handle}
+	futureTask := callableTask[T]{Task: task, Handle: newFuture[T]()}
 	err := t.submitTask(futureTask)
 	if err != nil {
 		return nil, err
 	}
 	return futureTask.Handle, nil
+}
+
+// Shutdown stops accepting tasks, the queued ones still run
+// It does not wait for them, see AwaitTermination
+func (t *ThreadPool) Shutdown() {
+	t.mutex.Lock()
+	atomic.CompareAndSwapInt32(&t.state, stateRunning, stateShutdown)
+	t.mutex.Unlock()
+
+	t.stopSubmitters()
+	t.cancelShutdown()
+}
+
+// ShutdownNow stops accepting tasks and returns the ones which have not started yet
+// Running tasks are not interrupted, the workers exit once they are done
+func (t *ThreadPool) ShutdownNow() []Runnable {
+	t.mutex.Lock()
+	if atomic.LoadInt32(&t.state) < stateStop {
+		atomic.StoreInt32(&t.state, stateStop)
+	}
+	t.stopping = true
+	t.workerIdle.Broadcast()
+	t.mutex.Unlock()
+
+	t.stopSubmitters()
+	t.cancelShutdown()
+	<-t.dispatcherDone
+
+	// Submitters which got past the state check before the stop may still be queueing
+	<-t.submittersContext.Done()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var pending []Runnable
+	if t.leftover != nil {
+		pending = append(pending, t.leftover.task)
+		t.leftover = nil
+	}
+	return append(pending, unwrapTasks(t.jobQueue.Drain())...)
+}
+
+// AwaitTermination blocks until all tasks have completed after a shutdown, or until the context is done
+func (t *ThreadPool) AwaitTermination(ctx context.Context) error {
+	select {
+	case <-t.terminated:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsShutdown returns true once Shutdown or ShutdownNow has been called
+func (t *ThreadPool) IsShutdown() bool {
+	return atomic.LoadInt32(&t.state) != stateRunning
+}
 
+// IsTerminated returns true once all tasks have completed after a shutdown
+func (t *ThreadPool) IsTerminated() bool {
+	return atomic.LoadInt32(&t.state) == stateTerminated
 }
 
+// Close shuts the pool down, the queued tasks still run
 func (t *ThreadPool) Close() {
-	close(t.closeHandle) // Stops all the routines
-	close(t.workerPool)  // Closes the Job threadpool
-	close(t.jobQueue)    // Closes the job Queue
+	t.Shutdown()
 }
\ No newline at end of file
@@ -0,0 +1,203 @@
+package threadpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !condition(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+	}
+}
+
+func TestElasticPoolSize(t *testing.T) {
+	pool := NewThreadPool(1, 10, WithMaximumPoolSize(4), WithKeepAliveTime(20*time.Millisecond))
+	if pool.PoolSize() != 0 || pool.PrestartAllCoreThreads() != 1 || pool.PoolSize() != 1 {
+		t.Fatal("expected the core worker to start on demand")
+	}
+
+	release := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		_ = pool.Execute(RunnableFunc(func() { <-release }))
+	}
+	waitFor(t, func() bool { return pool.PoolSize() == 4 })
+
+	close(release)
+	waitFor(t, func() bool { return pool.PoolSize() == 1 })
+}
+
+func TestResizePool(t *testing.T) {
+	pool := NewThreadPool(2, 10, WithMaximumPoolSize(4), WithKeepAliveTime(time.Hour))
+
+	if err := pool.SetCorePoolSize(5); err != ErrPoolSize {
+		t.Fatalf("expected the core size above the maximum to fail, got %v", err)
+	}
+	if err := pool.SetMaximumPoolSize(1); err != ErrPoolSize {
+		t.Fatalf("expected the maximum size below the core to fail, got %v", err)
+	}
+
+	release := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		_ = pool.Execute(RunnableFunc(func() { <-release }))
+	}
+	waitFor(t, func() bool { return pool.PoolSize() == 4 })
+
+	_ = pool.SetCorePoolSize(1)
+	_ = pool.SetMaximumPoolSize(2)
+	close(release)
+	waitFor(t, func() bool { return pool.PoolSize() == 2 })
+}
+
+func TestShutdownRunsQueuedTasks(t *testing.T) {
+	pool := NewThreadPool(1, 10)
+
+	var ran int32
+	for i := 0; i < 5; i++ {
+		_ = pool.Execute(RunnableFunc(func() {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&ran, 1)
+		}))
+	}
+	pool.Shutdown()
+
+	if err := pool.Execute(RunnableFunc(func() {})); err != ErrPoolShutdown {
+		t.Fatalf("expected the pool to be shut down, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !pool.IsShutdown() || !pool.IsTerminated() || atomic.LoadInt32(&ran) != 5 {
+		t.Fatalf("expected all 5 queued tasks to run before terminating, %d ran", ran)
+	}
+}
+
+func TestShutdownNowReturnsPendingTasks(t *testing.T) {
+	pool, release, queued := blockPool(t)
+
+	pending := pool.ShutdownNow()
+	if len(pending) != 2 {
+		t.Fatalf("expected the queued and the filler task back, got %d", len(pending))
+	}
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if queued.IsDone() {
+		t.Fatal("expected the pending task not to run")
+	}
+}
+
+func TestShutdownWaitsForCallerRunsSubmitter(t *testing.T) {
+	pool, release, queued := blockPool(t, WithRejectedExecutionHandler(CallerRunsPolicy{}))
+
+	// the rejected task runs inside Execute, the submitter stays registered until it returns
+	running, callerRelease := make(chan struct{}), make(chan struct{})
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- pool.Execute(RunnableFunc(func() {
+			close(running)
+			<-callerRelease
+		}))
+	}()
+	<-running
+
+	pool.Shutdown()
+	close(release)
+	if _, err := queued.GetTimeout(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the pool to wait for the submitter, got %v", err)
+	}
+
+	close(callerRelease)
+	if err := <-submitted; err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.AwaitTermination(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPanicReplacesWorker(t *testing.T) {
+	var reported int32
+	pool := NewThreadPool(2, 10, WithPanicHandler(PanicHandlerFunc(func(task Runnable, err *PanicError) {
+		atomic.AddInt32(&reported, 1)
+	})))
+	defer pool.Shutdown()
+	pool.PrestartAllCoreThreads()
+
+	future, _ := Submit[int](pool, CallableFunc[int](func() (int, error) { panic("boom") }))
+	if _, err := future.GetTimeout(time.Second); !errors.Is(err, ErrTaskPanicked) {
+		t.Fatalf("expected the panic on the future, got %v", err)
+	}
+	_ = pool.Execute(RunnableFunc(func() { panic("boom") }))
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&reported) == 2 })
+	waitFor(t, func() bool { return pool.PoolSize() == 2 })
+
+	result, err := Submit[int](pool, CallableFunc[int](func() (int, error) { return 1, nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := result.GetTimeout(time.Second); value != 1 || err != nil {
+		t.Fatalf("expected the replaced workers to keep running tasks, got %d %v", value, err)
+	}
+}
+
+func TestHooksAndStats(t *testing.T) {
+	var before, after, panicked int32
+	terminated := make(chan struct{})
+	pool := NewThreadPool(1, 1,
+		WithBeforeExecute(func(Runnable) { atomic.AddInt32(&before, 1) }),
+		WithAfterExecute(func(task Runnable, err error) {
+			atomic.AddInt32(&after, 1)
+			if errors.Is(err, ErrTaskPanicked) {
+				atomic.AddInt32(&panicked, 1)
+			}
+		}),
+		WithTerminated(func() { close(terminated) }),
+		WithPanicHandler(PanicHandlerFunc(func(Runnable, *PanicError) {})),
+	)
+
+	release := make(chan struct{})
+	_ = pool.Execute(RunnableFunc(func() { <-release }))
+	waitFor(t, func() bool { return pool.Stats().ActiveWorkers == 1 })
+	_ = pool.Execute(RunnableFunc(func() { panic("boom") }))
+	waitFor(t, func() bool { return pool.Stats().QueueDepth == 0 })
+	_ = pool.Execute(RunnableFunc(func() {}))
+	if err := pool.Execute(RunnableFunc(func() {})); err != ErrQueueFull {
+		t.Fatalf("expected the queue to be full, got %v", err)
+	}
+
+	close(release)
+	pool.Shutdown()
+	<-terminated
+
+	stats := pool.Stats()
+	if stats.Completed != 3 || stats.Rejected != 1 || stats.ActiveWorkers != 0 || stats.Execution.Count() != 3 || stats.QueueWait.Count() != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if before != 3 || after != 3 || panicked != 1 {
+		t.Fatalf("expected the hooks around every task, got %d before, %d after and %d panics", before, after, panicked)
+	}
+	if stats.Execution.Percentile(1) < stats.Execution.Percentile(0.5) || stats.Execution.Percentile(1) == 1<<47 {
+		t.Fatal("expected the execution latency to be recorded")
+	}
+}
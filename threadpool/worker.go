@@ -1,43 +1,117 @@
 package threadpool
 
-// Worker type holds the job channel and passed worker threadpool
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Worker type holds the job channel and the threadpool it registers itself with when idle
 type Worker struct {
-	jobChannel		chan interface{}
-	workerPool		chan chan interface{}
+	jobChannel		chan queuedTask
+	pool			*ThreadPool
 	closeHandle		chan bool
+	// armed while the worker is idle, it is reused so that running a job does not leave a timer behind
+	idleTimer		*time.Timer
 }
 
-func NewWorker (workerPool chan chan interface{}, closeHandle chan bool) *Worker {
-	return &Worker{workerPool: workerPool, jobChannel: make(chan interface{}), closeHandle: closeHandle}
+func NewWorker (pool *ThreadPool) *Worker {
+	idleTimer := time.NewTimer(time.Hour)
+	idleTimer.Stop()
+	return &Worker{pool: pool, jobChannel: make(chan queuedTask), closeHandle: pool.closeHandle, idleTimer: idleTimer}
 }
 
-func (w Worker) Start() {
+func (w *Worker) Start() {
 	go func() {
+		defer w.pool.workers.Done()
+
+		registered := false
 		for {
-			// Put the worker to the worker threadpool
-			w.workerPool <- w.jobChannel
+			// Put the worker to the idle workers of the threadpool
+			if !registered {
+				if !w.pool.release(w) {
+					return
+				}
+				registered = true
+			}
 
 			select {
 				// Wait for the job
-			case job := <-w.jobChannel:
-			// Got the job
-			w.executeJob(job)
+			case job, ok := <-w.jobChannel:
+				// The pool closes the channel of the workers it does not need anymore
+				w.stopKeepAlive()
+				if !ok {
+					return
+				}
+				registered = false
+				if !w.executeJob(job) {
+					// The worker is lost, the pool starts a new one in its place
+					w.pool.replace(w)
+					return
+				}
+			case <-w.keepAlive():
+				// Either the worker retires or the dispatcher has just picked it
+				if w.pool.retire(w) {
+					return
+				}
 			case <- w.closeHandle:
+				w.stopKeepAlive()
+				w.pool.exit(w)
 				return
 			}
 		}
 	}()
 }
 
-func (w Worker) executeJob(job interface{}) {
-	switch task := job.(type) {
-	case Runnable :
-		task.Run()
-		break
-	case callableTask:
-		response := task.Task.Call()
-		task.Handle.done = true
-		task.Handle.response <- response
-		break
+// keepAlive fires when the worker has been idle for the keep alive time of the pool
+func (w *Worker) keepAlive() <-chan time.Time {
+	if keepAlive := w.pool.KeepAliveTime(); keepAlive > 0 {
+		w.idleTimer.Reset(keepAlive)
+		return w.idleTimer.C
+	}
+	return nil
+}
+
+// stopKeepAlive disarms the idle timer and drops an expiry which nobody has received yet
+func (w *Worker) stopKeepAlive() {
+	if !w.idleTimer.Stop() {
+		select {
+		case <-w.idleTimer.C:
+		default:
+		}
 	}
-}
\ No newline at end of file
+}
+
+// executeJob runs the job between the hooks of the pool, callable tasks complete their future from Run
+// It returns false when the job panicked, the panic is handed to the PanicHandler of the pool
+func (w *Worker) executeJob(job queuedTask) (completed bool) {
+	pool := w.pool
+	started := time.Now()
+	pool.metrics.queueWait.record(started.Sub(job.enqueued))
+	atomic.AddInt32(&pool.metrics.active, 1)
+
+	defer func() {
+		var panicked *PanicError
+		var err error
+		if recovered := recover(); recovered != nil {
+			panicked = newPanicError(recovered)
+			err = panicked
+		}
+
+		pool.metrics.execution.record(time.Since(started))
+		atomic.AddUint64(&pool.metrics.completed, 1)
+		atomic.AddInt32(&pool.metrics.active, -1)
+
+		if pool.afterExecute != nil {
+			pool.afterExecute(job.task, err)
+		}
+		if panicked != nil {
+			pool.panicHandler.HandlePanic(job.task, panicked)
+		}
+	}()
+
+	if pool.beforeExecute != nil {
+		pool.beforeExecute(job.task)
+	}
+	job.task.Run()
+	return true
+}